@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gic/internal/auth"
+	"gic/internal/client"
+
+	"github.com/spf13/cobra"
+	"github.com/yarlson/tap"
+)
+
+var (
+	createAPIKey bool
+
+	authCmd = &cobra.Command{
+		Use:           "auth",
+		Short:         "Inspect and choose how gic authenticates with Anthropic",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuthStatus(cmd.Context())
+		},
+	}
+
+	authStatusCmd = &cobra.Command{
+		Use:           "status",
+		Short:         "Show the active credential and where it comes from",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuthStatus(cmd.Context())
+		},
+	}
+
+	authLoginCmd = &cobra.Command{
+		Use:           "login",
+		Short:         "Sign in with a claude.ai account (Pro/Max)",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokenPath, _, err := authPaths()
+			if err != nil {
+				return err
+			}
+
+			tap.Intro("🔐 Sign in with claude.ai")
+
+			_, err = performOAuthFlow(cmd.Context(), tokenPath)
+
+			return err
+		},
+	}
+
+	authAPIKeyCmd = &cobra.Command{
+		Use:           "api-key",
+		Short:         "Store a Console API key",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuthAPIKey(cmd.Context())
+		},
+	}
+
+	authUseCmd = &cobra.Command{
+		Use:           "use <auto|oauth|api-key>",
+		Short:         "Choose which credential type gic uses",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuthUse(args[0])
+		},
+	}
+
+	authLogoutCmd = &cobra.Command{
+		Use:           "logout",
+		Short:         "Remove the stored OAuth token and API key",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAuthLogout()
+		},
+	}
+)
+
+func init() {
+	authAPIKeyCmd.Flags().BoolVar(&createAPIKey, "create", false, "Create a new key by signing in to the Anthropic Console")
+	authCmd.AddCommand(authStatusCmd, authLoginCmd, authAPIKeyCmd, authUseCmd, authLogoutCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+// runAuthStatus shows every available credential and which one is active.
+func runAuthStatus(ctx context.Context) error {
+	tokenPath, credentialsPath, err := authPaths()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	providerConfig := cfg.ProviderConfig()
+
+	creds, err := auth.LoadCredentials(credentialsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	token, err := auth.Load(tokenPath)
+	if err != nil {
+		return fmt.Errorf("failed to load token: %w", err)
+	}
+
+	envName := providerConfig.APIKeyEnv
+	if envName == "" {
+		envName = auth.DefaultAPIKeyEnv
+	}
+
+	preference := creds.Method
+	if preference == "" {
+		preference = auth.MethodAuto
+	}
+
+	var b strings.Builder
+
+	if providerConfig.IsAnthropic() {
+		cred, err := resolveCredential(credentialsPath, providerConfig)
+		if err != nil {
+			b.WriteString(fmt.Sprintf("Active:       none (%v)\n", err))
+		} else {
+			b.WriteString(fmt.Sprintf("Active:       %s (%s)\n", cred.Method, cred.Source))
+		}
+	} else {
+		b.WriteString(fmt.Sprintf("Active:       %s provider, Anthropic credentials unused\n", providerConfig.Name))
+	}
+
+	b.WriteString(fmt.Sprintf("Preference:   %s\n", preference))
+
+	if key := os.Getenv(envName); key != "" {
+		b.WriteString(fmt.Sprintf("%-13s %s\n", envName+":", auth.MaskKey(key)))
+	} else {
+		b.WriteString(fmt.Sprintf("%-13s not set\n", envName+":"))
+	}
+
+	if creds.APIKey != "" {
+		b.WriteString(fmt.Sprintf("Stored key:   %s\n", auth.MaskKey(creds.APIKey)))
+	} else {
+		b.WriteString("Stored key:   none\n")
+	}
+
+	switch {
+	case token == nil:
+		b.WriteString("OAuth token:  none")
+	case token.IsValid():
+		b.WriteString(fmt.Sprintf("OAuth token:  valid until %s", time.Unix(token.ExpiresAt, 0).Format(time.RFC1123)))
+	default:
+		b.WriteString("OAuth token:  expired, refreshed on next use")
+	}
+
+	tap.Intro("🔐 gic auth")
+
+	tap.Box(b.String(), "Credentials", tap.BoxOptions{
+		TitleAlign:     tap.BoxAlignLeft,
+		ContentAlign:   tap.BoxAlignLeft,
+		TitlePadding:   1,
+		ContentPadding: 1,
+		Rounded:        true,
+		IncludePrefix:  true,
+		FormatBorder:   tap.GrayBorder,
+	})
+
+	switch preference {
+	case auth.MethodOAuth:
+		tap.Outro("Only the OAuth token is used; run 'gic auth use auto' to allow API keys")
+	case auth.MethodAPIKey:
+		tap.Outro(fmt.Sprintf("Precedence: %s, then stored key; OAuth is never used", envName))
+	default:
+		tap.Outro(fmt.Sprintf("Precedence: %s, then stored key, then OAuth token", envName))
+	}
+
+	return nil
+}
+
+// runAuthAPIKey stores an API key, either pasted or created through the
+// Console OAuth flow.
+func runAuthAPIKey(ctx context.Context) error {
+	_, credentialsPath, err := authPaths()
+	if err != nil {
+		return err
+	}
+
+	creds, err := auth.LoadCredentials(credentialsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	tap.Intro("🔑 Store an API key")
+
+	var apiKey string
+
+	if createAPIKey {
+		token, sp, err := authorize(ctx, true)
+		if err != nil {
+			return err
+		}
+
+		sp.Stop("Signed in to the Anthropic Console", 0)
+
+		apiKey, err = client.CreateAPIKey(token.AccessToken)
+		if err != nil {
+			return err
+		}
+	} else {
+		apiKey = strings.TrimSpace(tap.Password(ctx, tap.PasswordOptions{
+			Message: "Paste your Anthropic API key:",
+		}))
+	}
+
+	if apiKey == "" {
+		return fmt.Errorf("no API key given")
+	}
+
+	creds.APIKey = apiKey
+
+	if err := auth.SaveCredentials(creds, credentialsPath); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+
+	tap.Outro(fmt.Sprintf("Stored %s", auth.MaskKey(apiKey)))
+
+	return nil
+}
+
+// runAuthUse stores the preferred credential type.
+func runAuthUse(name string) error {
+	method, err := auth.ParseMethod(name)
+	if err != nil {
+		return err
+	}
+
+	_, credentialsPath, err := authPaths()
+	if err != nil {
+		return err
+	}
+
+	creds, err := auth.LoadCredentials(credentialsPath)
+	if err != nil {
+		return fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	creds.Method = method
+
+	if err := auth.SaveCredentials(creds, credentialsPath); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+
+	tap.Outro(fmt.Sprintf("gic now authenticates with: %s", method))
+
+	return nil
+}
+
+// runAuthLogout removes the stored token and credentials.
+func runAuthLogout() error {
+	tokenPath, credentialsPath, err := authPaths()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{tokenPath, credentialsPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	tap.Outro("Signed out; stored token and API key removed")
+
+	return nil
+}
@@ -6,20 +6,48 @@ import (
 	"strings"
 	"sync"
 
+	"gic/internal/client"
 	"gic/internal/commit"
 	"gic/internal/git"
+	"gic/internal/secrets"
 
 	"github.com/yarlson/tap"
 )
 
-// Run executes the commit workflow.
-func Run(accessToken, userInput string, autoApprove bool) error {
-	ctx := context.Background()
+// Options configures a single run of the commit workflow.
+type Options struct {
+	// AutoApprove skips the confirmation prompt.
+	AutoApprove bool
+	// Stage selects which changes are staged before generating the message.
+	Stage git.StageMode
+	// Paths are the pathspecs staged when Stage is git.StagePaths. In
+	// interactive mode they limit which changes are offered.
+	Paths []string
+	// Interactive lets the user pick individual hunks to stage instead of
+	// applying Stage.
+	Interactive bool
+	// Split asks Claude to break the staged changes into several commits.
+	Split bool
+	// Candidates is the number of alternative messages to choose from.
+	// Values below 2 generate a single message.
+	Candidates int
+	// Message configures how commit messages are generated.
+	Message commit.Options
+	// Secrets selects whether secrets in the diff are redacted or block the run.
+	Secrets secrets.Mode
+}
 
+// Run executes the commit workflow. Cancelling ctx stops in-flight git
+// commands and API requests.
+func Run(ctx context.Context, provider client.Provider, userInput string, opts Options) error {
 	tap.Intro("🤖 Git Commit Assistant")
 
-	// Step 1: Stage all changes first
-	if err := git.Add("."); err != nil {
+	// Step 1: Stage the selected changes
+	if opts.Interactive {
+		if err := pickHunks(ctx, opts.Paths); err != nil {
+			return err
+		}
+	} else if err := git.Stage(ctx, opts.Stage, opts.Paths); err != nil {
 		return fmt.Errorf("failed to stage changes: %w", err)
 	}
 
@@ -37,7 +65,7 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 	go func() {
 		defer wg.Done()
 
-		s, err := git.Status()
+		s, err := git.Status(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -54,7 +82,7 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 	go func() {
 		defer wg.Done()
 
-		stats, err := git.DiffStat()
+		stats, err := git.DiffStat(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -71,7 +99,7 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 	go func() {
 		defer wg.Done()
 
-		d, err := git.Diff()
+		d, err := git.Diff(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -88,7 +116,7 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 	go func() {
 		defer wg.Done()
 
-		l, err := git.Log()
+		l, err := git.Log(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -125,66 +153,79 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 		FormatBorder:   tap.GrayBorder,
 	})
 
+	// Hunks are split off the staged diff as is, before any redaction
+	var splittable map[string]git.FilePatch
+	if opts.Split {
+		splittable = commit.SplittableFiles(diff, fileStats)
+	}
+
+	// Keep secrets out of the prompt
+	diff, err := guardSecrets(diff, opts.Secrets)
+	if err != nil {
+		return err
+	}
+
 	// Step 3: Check if we need smart diff selection
-	totalSize := len(status) + len(diff) + len(log) + commit.PromptOverhead
+	summaryCtx, stopSummary := withSummaryStatus(ctx)
 
-	var smartDiff string
+	smartDiff, trimmed, err := commit.FitDiff(summaryCtx, provider, status, diff, log, fileStats, opts.Message)
+	summarized := stopSummary(err)
 
-	if totalSize > commit.MaxPromptChars {
-		tap.Message("⚠️  Large changeset detected, selecting most relevant files...")
+	if err != nil {
+		return err
+	}
 
-		smartDiff = commit.BuildSmartDiff(fileStats, diff, commit.MaxPromptChars-len(status)-len(log)-commit.PromptOverhead)
-	} else {
-		smartDiff = diff
+	if trimmed {
+		if !summarized {
+			tap.Message("⚠️  Large changeset detected, selecting most relevant files...")
+		}
+
+		if !opts.Split {
+			tap.Message("Tip: run with --split to break it into several commits")
+		}
 	}
 
-	// Step 4: Generate commit message with Claude
-	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
-	sp.Start("Generating commit message with Claude")
+	if opts.Split {
+		return runSplit(ctx, provider, status, smartDiff, log, fileStats, splittable, userInput, opts)
+	}
 
-	commitMsg, err := commit.GenerateMessage(accessToken, status, smartDiff, log, fileStats, userInput)
-	if err != nil {
-		sp.Stop("Failed to generate commit message", 2)
-		return fmt.Errorf("failed to generate commit message: %w", err)
+	// Step 4: Generate commit message with Claude
+	generate := func(ctx context.Context, revisions []commit.Revision, onText func(string)) (string, error) {
+		return commit.StreamMessage(ctx, provider, onText, status, smartDiff, log, fileStats, userInput, opts.Message, revisions...)
 	}
 
-	sp.Stop("Commit message generated               ", 0)
+	var commitMsg string
 
-	// Show proposed commit message
-	tap.Box(commitMsg, "📋 Proposed Commit Message", tap.BoxOptions{
-		TitleAlign:     tap.BoxAlignLeft,
-		ContentAlign:   tap.BoxAlignLeft,
-		TitlePadding:   1,
-		ContentPadding: 1,
-		Rounded:        true,
-		IncludePrefix:  true,
-		FormatBorder:   tap.GrayBorder,
-	})
+	if opts.Candidates > 1 {
+		commitMsg, err = pickCandidate(ctx, func(ctx context.Context) ([]string, error) {
+			return commit.GenerateCandidates(ctx, provider, status, smartDiff, log, fileStats, userInput, opts.Message, opts.Candidates)
+		}, opts.AutoApprove)
+	} else {
+		commitMsg, err = generateWithPreview(ctx, generate, nil)
+	}
 
-	// Step 5: Ask for confirmation unless auto-approval requested
-	proceed := true
+	if err != nil {
+		return err
+	}
 
-	if autoApprove {
+	// Step 5: Review the message unless auto-approval requested
+	if opts.AutoApprove {
+		showMessage(commitMsg)
 		tap.Message("Auto-approve enabled; skipping confirmation prompt")
 	} else {
-		proceed = tap.Confirm(ctx, tap.ConfirmOptions{
-			Message:      "Proceed with commit?",
-			Active:       "Yes",
-			Inactive:     "No",
-			InitialValue: true,
-		})
+		commitMsg, err = reviewMessage(ctx, commitMsg, generate, opts.Message.Style)
+		if err != nil {
+			return err
+		}
 	}
 
-	if !proceed {
-		tap.Message("Commit cancelled")
-		return fmt.Errorf("commit cancelled")
-	}
+	reportUsage(provider)
 
 	// Step 6: Create commit
-	sp = tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
+	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
 	sp.Start("Creating commit")
 
-	if err := git.Commit(commitMsg); err != nil {
+	if err := git.Commit(ctx, commitMsg); err != nil {
 		sp.Stop("Failed to create commit", 2)
 		return fmt.Errorf("failed to create commit: %w", err)
 	}
@@ -194,3 +235,91 @@ func Run(accessToken, userInput string, autoApprove bool) error {
 
 	return nil
 }
+
+// withSummaryStatus returns a context that shows a spinner while files too
+// large for the prompt are summarised, and a function that stops it and
+// reports whether any summaries were made.
+func withSummaryStatus(ctx context.Context) (context.Context, func(err error) bool) {
+	var (
+		sp *tap.Spinner
+		mu sync.Mutex
+	)
+
+	ctx = commit.WithSummaryProgress(ctx, func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sp == nil {
+			tap.Message("⚠️  Large changeset detected, summarizing files that do not fit...")
+
+			sp = tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
+			sp.Start("Summarizing large files")
+		}
+
+		sp.Message(fmt.Sprintf("Summarizing large files (%d/%d)", done, total))
+	})
+
+	return ctx, func(err error) bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if sp == nil {
+			return false
+		}
+
+		if err != nil {
+			sp.Stop("Failed to summarize large files", 2)
+		} else {
+			sp.Stop("Summarized large files", 0)
+		}
+
+		return true
+	}
+}
+
+// reportUsage shows the tokens consumed so far when the provider tracks them.
+func reportUsage(provider client.Provider) {
+	reporter, ok := provider.(client.UsageReporter)
+	if !ok {
+		return
+	}
+
+	usage := reporter.Usage()
+	if usage.InputTokens == 0 && usage.OutputTokens == 0 {
+		return
+	}
+
+	tap.Message(fmt.Sprintf("Tokens used: %d input, %d output", usage.InputTokens, usage.OutputTokens))
+}
+
+// guardSecrets applies the secrets mode to the diff and tells the user what
+// was redacted or why the run stopped.
+func guardSecrets(diff string, mode secrets.Mode) (string, error) {
+	safeDiff, findings, err := secrets.Guard(diff, mode)
+	if len(findings) == 0 {
+		return safeDiff, err
+	}
+
+	title := "🔒 Redacted Secrets"
+	if err != nil {
+		title = "🚫 Secrets Detected"
+	}
+
+	tap.Box(secrets.Report(findings), title, tap.BoxOptions{
+		TitleAlign:     tap.BoxAlignLeft,
+		ContentAlign:   tap.BoxAlignLeft,
+		TitlePadding:   1,
+		ContentPadding: 1,
+		Rounded:        true,
+		IncludePrefix:  true,
+		FormatBorder:   tap.GrayBorder,
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("diff contains %d potential secrets; remove them or run with --secrets redact", len(findings))
+	}
+
+	tap.Message(fmt.Sprintf("Replaced %d potential secrets with placeholders before sending the diff", len(findings)))
+
+	return safeDiff, nil
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gic/internal/git"
+
+	"github.com/yarlson/tap"
+)
+
+// hunkRef identifies one selectable hunk in the picker.
+type hunkRef struct {
+	File int
+	Hunk int
+}
+
+// pickHunks shows every pending file and hunk in a multi-select and stages
+// exactly the chosen selection on top of the current index.
+func pickHunks(ctx context.Context, paths []string) error {
+	diff, err := git.WorktreeDiff(ctx, paths)
+	if err != nil {
+		return fmt.Errorf("failed to read worktree changes: %w", err)
+	}
+
+	files := git.ParsePatch(diff)
+
+	untracked, err := git.UntrackedFiles(ctx, paths)
+	if err != nil {
+		return fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	for _, path := range untracked {
+		files = append(files, git.FilePatch{Path: path, Untracked: true})
+	}
+
+	if len(files) == 0 {
+		return nil
+	}
+
+	var (
+		options []tap.SelectOption[hunkRef]
+		initial []hunkRef
+	)
+
+	for fi, file := range files {
+		if len(file.Hunks) == 0 {
+			ref := hunkRef{File: fi}
+			hint := "whole file"
+
+			if file.Untracked {
+				hint = "new file"
+			}
+
+			options = append(options, tap.SelectOption[hunkRef]{Value: ref, Label: file.Path, Hint: hint})
+			initial = append(initial, ref)
+
+			continue
+		}
+
+		for hi, hunk := range file.Hunks {
+			ref := hunkRef{File: fi, Hunk: hi}
+
+			options = append(options, tap.SelectOption[hunkRef]{
+				Value: ref,
+				Label: fmt.Sprintf("%s %s", file.Path, strings.TrimSpace(hunk.Header())),
+				Hint:  hunkHint(hunk),
+			})
+			initial = append(initial, ref)
+		}
+	}
+
+	selected := tap.MultiSelect(ctx, tap.MultiSelectOptions[hunkRef]{
+		Message:       "Select the hunks to include in this commit",
+		Options:       options,
+		InitialValues: initial,
+	})
+
+	if len(selected) == 0 {
+		return fmt.Errorf("no hunks selected")
+	}
+
+	byFile := make(map[int][]int)
+	for _, ref := range selected {
+		byFile[ref.File] = append(byFile[ref.File], ref.Hunk)
+	}
+
+	var patch strings.Builder
+
+	for fi, file := range files {
+		hunks, ok := byFile[fi]
+		if !ok {
+			continue
+		}
+
+		if file.Untracked {
+			if err := git.Add(ctx, "--", file.Path); err != nil {
+				return fmt.Errorf("failed to stage %s: %w", file.Path, err)
+			}
+
+			continue
+		}
+
+		sort.Ints(hunks)
+		patch.WriteString(file.Select(hunks))
+	}
+
+	if err := git.ApplyCached(ctx, patch.String()); err != nil {
+		return fmt.Errorf("failed to stage selected hunks: %w", err)
+	}
+
+	return nil
+}
+
+// hunkHint summarises a hunk by its line counts and first changed line.
+func hunkHint(hunk git.Hunk) string {
+	hint := fmt.Sprintf("+%d -%d", hunk.Added(), hunk.Removed())
+
+	for _, line := range hunk.Lines {
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		first := []rune(strings.TrimSpace(line[1:]))
+		if len(first) > 60 {
+			first = append(first[:57], []rune("...")...)
+		}
+
+		if len(first) > 0 {
+			hint += ": " + string(first)
+		}
+
+		break
+	}
+
+	return hint
+}
@@ -0,0 +1,402 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gic/internal/client"
+	"gic/internal/commit"
+
+	"github.com/yarlson/tap"
+	"golang.org/x/term"
+)
+
+// reviewAction is a choice in the confirmation menu.
+type reviewAction string
+
+const (
+	actionAccept     reviewAction = "accept"
+	actionRegenerate reviewAction = "regenerate"
+	actionRefine     reviewAction = "refine"
+	actionEdit       reviewAction = "edit"
+	actionCancel     reviewAction = "cancel"
+)
+
+// generateFunc produces a commit message, refining earlier drafts when
+// revisions are given. The response so far is passed to onText while it is
+// generated.
+type generateFunc func(ctx context.Context, revisions []commit.Revision, onText func(string)) (string, error)
+
+// reviewMessage shows the proposed message and loops over the confirmation
+// menu until the user accepts or cancels. It returns the accepted message.
+// Edited messages must follow style like generated ones.
+func reviewMessage(ctx context.Context, commitMsg string, generate generateFunc, style commit.Style) (string, error) {
+	var (
+		revisions []commit.Revision
+		// rejected is an edited message that broke the style, reopened by
+		// the next edit so that the user's changes are not lost
+		rejected string
+	)
+
+	for {
+		showMessage(commitMsg)
+
+		action := tap.Select(ctx, tap.SelectOptions[reviewAction]{
+			Message: "What would you like to do?",
+			Options: []tap.SelectOption[reviewAction]{
+				{Value: actionAccept, Label: "Commit", Hint: "create the commit with this message"},
+				{Value: actionRegenerate, Label: "Regenerate", Hint: "ask Claude for a fresh message"},
+				{Value: actionRefine, Label: "Refine", Hint: "tell Claude what to change"},
+				{Value: actionEdit, Label: "Edit", Hint: "open the message in $EDITOR"},
+				{Value: actionCancel, Label: "Cancel"},
+			},
+		})
+
+		switch action {
+		case actionAccept:
+			return commitMsg, nil
+		case actionRegenerate:
+			msg, err := generateWithPreview(ctx, generate, nil)
+			if err != nil {
+				return "", err
+			}
+
+			revisions, rejected = nil, ""
+			commitMsg = msg
+		case actionRefine:
+			feedback := strings.TrimSpace(tap.Text(ctx, tap.TextOptions{
+				Message:     "How should the message change?",
+				Placeholder: "e.g. mention the cache invalidation fix",
+			}))
+			if feedback == "" {
+				continue
+			}
+
+			next := append(revisions, commit.Revision{Draft: commitMsg, Feedback: feedback})
+
+			msg, err := generateWithPreview(ctx, generate, next)
+			if err != nil {
+				return "", err
+			}
+
+			revisions, rejected = next, ""
+			commitMsg = msg
+		case actionEdit:
+			draft := commitMsg
+			if rejected != "" {
+				draft = rejected
+			}
+
+			msg, err := editMessage(draft)
+			if err != nil {
+				tap.Message(fmt.Sprintf("Editor failed: %v", err))
+				continue
+			}
+
+			if msg == "" {
+				tap.Message("Empty message; keeping the previous one")
+				continue
+			}
+
+			if err := style.Validate(msg); err != nil {
+				rejected = msg
+
+				tap.Message(fmt.Sprintf("The edited message does not follow the %s style: %v\nKeeping the previous one; choose Edit to fix it", style, err))
+
+				continue
+			}
+
+			rejected = ""
+			commitMsg = msg
+		default:
+			tap.Message("Commit cancelled")
+			return "", fmt.Errorf("commit cancelled")
+		}
+	}
+}
+
+// generateWithPreview runs generate while rendering the message live as
+// Claude writes it. Cancelling ctx (Ctrl-C) aborts the in-flight request.
+func generateWithPreview(ctx context.Context, generate generateFunc, revisions []commit.Revision) (string, error) {
+	preview := newLivePreview()
+	preview.stream.Start("Generating commit message with Claude")
+
+	ctx = client.WithRetryNotify(ctx, func(r client.Retry) {
+		preview.restart("⏳ " + r.String())
+	})
+
+	msg, err := generate(ctx, revisions, preview.update)
+	if err != nil {
+		if ctx.Err() != nil {
+			preview.stop("Generation cancelled", 1)
+			return "", fmt.Errorf("commit cancelled")
+		}
+
+		preview.stop("Failed to generate commit message", 2)
+
+		return "", fmt.Errorf("failed to generate commit message: %w", err)
+	}
+
+	preview.stop("Commit message generated", 0)
+
+	return msg, nil
+}
+
+// withRetryStatus shows retries of API requests made with the returned
+// context in the spinner text, after msg.
+func withRetryStatus(ctx context.Context, sp *tap.Spinner, msg string) context.Context {
+	return client.WithRetryNotify(ctx, func(r client.Retry) {
+		sp.Message(fmt.Sprintf("%s (%s)", msg, r))
+	})
+}
+
+// livePreview writes a streamed response into a tap stream one line at a
+// time. The line being written is drawn below the stream and rewritten in
+// place on every update, so that a one-line subject shows up as it arrives;
+// once complete it is handed to the stream.
+type livePreview struct {
+	stream *tap.Stream
+	text   string
+	shown  int
+	// width is the terminal width, or 0 when stdout is not a terminal and
+	// the unfinished line cannot be rewritten.
+	width int
+	// partial is set while an unfinished line is drawn.
+	partial bool
+}
+
+func newLivePreview() *livePreview {
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width = 0
+	}
+
+	return &livePreview{stream: tap.NewStream(tap.StreamOptions{ShowTimer: true}), width: width}
+}
+
+// update receives the response generated so far.
+func (p *livePreview) update(text string) {
+	// A re-asked attempt starts over; keep the rejected draft visible above it
+	if !strings.HasPrefix(text, p.text) {
+		p.restart("")
+	}
+
+	p.text = text
+
+	lines := strings.Split(text, "\n")
+	if p.shown < len(lines)-1 {
+		p.clearPartial()
+	}
+
+	for ; p.shown < len(lines)-1; p.shown++ {
+		p.stream.WriteLine(lines[p.shown])
+	}
+
+	p.drawPartial(lines[len(lines)-1])
+}
+
+// drawPartial rewrites the terminal line below the stream with the unfinished
+// line, styled like the stream's own lines. It is cut to the terminal width,
+// since a line that wrapped could not be rewritten.
+func (p *livePreview) drawPartial(line string) {
+	if p.width == 0 || (line == "" && !p.partial) {
+		return
+	}
+
+	// The bar and its padding take three columns; one more avoids wrapping
+	if runes := []rune(line); len(runes) > p.width-4 {
+		line = string(runes[:max(p.width-4, 0)])
+	}
+
+	fmt.Fprint(os.Stdout, tap.EraseLine+tap.CyanBorder(tap.Bar)+"  "+line)
+
+	p.partial = true
+}
+
+// clearPartial erases the unfinished line, leaving the cursor where the
+// stream writes its next line.
+func (p *livePreview) clearPartial() {
+	if p.partial {
+		fmt.Fprint(os.Stdout, tap.EraseLine)
+
+		p.partial = false
+	}
+}
+
+// flush writes the trailing line that has not been terminated yet.
+func (p *livePreview) flush() {
+	p.clearPartial()
+
+	lines := strings.Split(p.text, "\n")
+	if p.shown < len(lines) && lines[len(lines)-1] != "" {
+		p.stream.WriteLine(lines[len(lines)-1])
+	}
+
+	p.shown = len(lines)
+}
+
+// stop flushes the preview and finalizes the stream with msg and code as for
+// tap.Stream.Stop.
+func (p *livePreview) stop(msg string, code int) {
+	p.flush()
+	p.stream.Stop(msg, code)
+}
+
+// restart finishes the current draft with a note line, so that the next
+// update starts a new one.
+func (p *livePreview) restart(note string) {
+	p.flush()
+	p.stream.WriteLine(note)
+
+	p.text = ""
+	p.shown = 0
+}
+
+// pickCandidate asks Claude for several messages and lets the user choose one.
+// With autoApprove the first candidate is taken without prompting.
+func pickCandidate(ctx context.Context, generate func(ctx context.Context) ([]string, error), autoApprove bool) (string, error) {
+	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
+	sp.Start("Generating candidate messages with Claude")
+
+	candidates, err := generate(withRetryStatus(ctx, sp, "Generating candidate messages with Claude"))
+	if err != nil {
+		sp.Stop("Failed to generate commit messages", 2)
+		return "", fmt.Errorf("failed to generate commit messages: %w", err)
+	}
+
+	sp.Stop(fmt.Sprintf("%d candidate messages generated               ", len(candidates)), 0)
+
+	if autoApprove || len(candidates) == 1 {
+		return candidates[0], nil
+	}
+
+	var (
+		listing strings.Builder
+		options []tap.SelectOption[int]
+	)
+
+	for i, msg := range candidates {
+		if i > 0 {
+			listing.WriteString("\n\n")
+		}
+
+		listing.WriteString(fmt.Sprintf("%d. %s", i+1, msg))
+
+		subject, body, _ := strings.Cut(msg, "\n")
+
+		hint := ""
+		if body = strings.TrimSpace(body); body != "" {
+			hint = fmt.Sprintf("+%d lines", strings.Count(body, "\n")+1)
+		}
+
+		options = append(options, tap.SelectOption[int]{
+			Value: i + 1,
+			Label: fmt.Sprintf("%d. %s", i+1, subject),
+			Hint:  hint,
+		})
+	}
+
+	// Select returns the zero value when cancelled, so 0 cannot be a candidate
+	options = append(options, tap.SelectOption[int]{Value: 0, Label: "Cancel"})
+
+	tap.Box(listing.String(), "📋 Candidate Commit Messages", tap.BoxOptions{
+		TitleAlign:     tap.BoxAlignLeft,
+		ContentAlign:   tap.BoxAlignLeft,
+		TitlePadding:   1,
+		ContentPadding: 1,
+		Rounded:        true,
+		IncludePrefix:  true,
+		FormatBorder:   tap.GrayBorder,
+	})
+
+	choice := tap.Select(ctx, tap.SelectOptions[int]{
+		Message: "Which message should be used?",
+		Options: options,
+	})
+
+	if choice < 1 || choice > len(candidates) {
+		tap.Message("Commit cancelled")
+		return "", fmt.Errorf("commit cancelled")
+	}
+
+	return candidates[choice-1], nil
+}
+
+// showMessage displays a proposed commit message.
+func showMessage(commitMsg string) {
+	tap.Box(commitMsg, "📋 Proposed Commit Message", tap.BoxOptions{
+		TitleAlign:     tap.BoxAlignLeft,
+		ContentAlign:   tap.BoxAlignLeft,
+		TitlePadding:   1,
+		ContentPadding: 1,
+		Rounded:        true,
+		IncludePrefix:  true,
+		FormatBorder:   tap.GrayBorder,
+	})
+}
+
+// editMessage opens the message in the user's editor ($VISUAL, $EDITOR or vi)
+// and returns the edited text with comment lines removed.
+func editMessage(commitMsg string) (string, error) {
+	f, err := os.CreateTemp("", "gic-COMMIT_EDITMSG-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	content := commitMsg + "\n\n# Edit the commit message above. Lines starting with '#' are ignored.\n"
+	if _, err := f.WriteString(content); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	if editor == "" {
+		editor = "vi"
+	}
+
+	parts := strings.Fields(editor)
+
+	cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return stripComments(string(data)), nil
+}
+
+// stripComments drops '#' comment lines and surrounding blank lines.
+func stripComments(s string) string {
+	var lines []string
+
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gic/internal/client"
+	"gic/internal/commit"
+	"gic/internal/git"
+
+	"github.com/yarlson/tap"
+)
+
+// runSplit asks Claude to split the staged changes into several commits,
+// shows the plan for approval and creates the commits in order.
+func runSplit(ctx context.Context, provider client.Provider, status, diff, log string, fileStats []git.FileChange, splittable map[string]git.FilePatch, userInput string, opts Options) error {
+	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
+	sp.Start("Planning commits with Claude")
+
+	plan, err := commit.PlanCommits(withRetryStatus(ctx, sp, "Planning commits with Claude"), provider, status, diff, log, fileStats, splittable, userInput, opts.Message)
+	if err != nil {
+		sp.Stop("Failed to plan commits", 2)
+		return fmt.Errorf("failed to plan commits: %w", err)
+	}
+
+	sp.Stop(fmt.Sprintf("Planned %d commits               ", len(plan)), 0)
+
+	tap.Box(formatPlan(plan), "🧩 Proposed Commits", tap.BoxOptions{
+		TitleAlign:     tap.BoxAlignLeft,
+		ContentAlign:   tap.BoxAlignLeft,
+		TitlePadding:   1,
+		ContentPadding: 1,
+		Rounded:        true,
+		IncludePrefix:  true,
+		FormatBorder:   tap.GrayBorder,
+	})
+
+	reportUsage(provider)
+
+	proceed := true
+
+	if opts.AutoApprove {
+		tap.Message("Auto-approve enabled; skipping confirmation prompt")
+	} else {
+		proceed = tap.Confirm(ctx, tap.ConfirmOptions{
+			Message:      fmt.Sprintf("Create these %d commits?", len(plan)),
+			Active:       "Yes",
+			Inactive:     "No",
+			InitialValue: true,
+		})
+	}
+
+	if !proceed {
+		tap.Message("Commit cancelled")
+		return fmt.Errorf("commit cancelled")
+	}
+
+	if err := commitPlan(ctx, plan, fileStats, splittable); err != nil {
+		return err
+	}
+
+	tap.Outro("All done!")
+
+	return nil
+}
+
+// commitPlan creates the planned commits, showing progress in a spinner.
+func commitPlan(ctx context.Context, plan []commit.PlannedCommit, fileStats []git.FileChange, splittable map[string]git.FilePatch) error {
+	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
+	sp.Start(fmt.Sprintf("Creating commit 1/%d", len(plan)))
+
+	err := commit.CommitPlan(ctx, plan, fileStats, splittable, func(done int) {
+		if done < len(plan) {
+			sp.Message(fmt.Sprintf("Creating commit %d/%d", done+1, len(plan)))
+		}
+	})
+	if err != nil {
+		sp.Stop("Failed to create commits", 2)
+		return err
+	}
+
+	sp.Stop(fmt.Sprintf("%d commits created               ", len(plan)), 0)
+
+	return nil
+}
+
+// formatPlan renders a commit plan for display.
+func formatPlan(plan []commit.PlannedCommit) string {
+	var b strings.Builder
+
+	for i, c := range plan {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+
+		b.WriteString(fmt.Sprintf("%d. %s", i+1, c.Message))
+
+		for _, path := range c.Files {
+			b.WriteString("\n   • " + path)
+		}
+
+		for _, path := range slices.Sorted(maps.Keys(c.Hunks)) {
+			numbers := make([]string, len(c.Hunks[path]))
+			for j, hunk := range c.Hunks[path] {
+				numbers[j] = strconv.Itoa(hunk + 1)
+			}
+
+			b.WriteString(fmt.Sprintf("\n   • %s (hunks %s)", path, strings.Join(numbers, ", ")))
+		}
+	}
+
+	return b.String()
+}
@@ -391,6 +391,92 @@ func (s *AuthTestSuite) TestBuildAuthURLUniqueness() {
 	assert.True(s.T(), strings.Contains(url2, "state="+verifier2))
 }
 
+// TestCredentialsSaveAndLoad verifies stored credentials round-trip with private permissions
+func (s *AuthTestSuite) TestCredentialsSaveAndLoad() {
+	path := filepath.Join(s.tmpDir, "nested", auth.CredentialsFile)
+
+	creds, err := auth.LoadCredentials(path)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), &auth.Credentials{}, creds)
+
+	err = auth.SaveCredentials(&auth.Credentials{APIKey: "sk-ant-stored-0000", Method: auth.MethodAPIKey}, path)
+	require.NoError(s.T(), err)
+
+	info, err := os.Stat(path)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0600), info.Mode().Perm())
+
+	creds, err = auth.LoadCredentials(path)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "sk-ant-stored-0000", creds.APIKey)
+	assert.Equal(s.T(), auth.MethodAPIKey, creds.Method)
+}
+
+// TestResolvePrecedence verifies that the environment key wins over the stored key, which wins over OAuth
+func (s *AuthTestSuite) TestResolvePrecedence() {
+	path := filepath.Join(s.tmpDir, auth.CredentialsFile)
+	envName := "GIC_TEST_ANTHROPIC_KEY"
+
+	// Nothing configured: OAuth
+	cred, err := auth.Resolve(path, envName, auth.MethodAuto)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), auth.MethodOAuth, cred.Method)
+
+	// Stored key beats OAuth
+	require.NoError(s.T(), auth.SaveCredentials(&auth.Credentials{APIKey: "sk-ant-stored"}, path))
+
+	cred, err = auth.Resolve(path, envName, auth.MethodAuto)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), auth.MethodAPIKey, cred.Method)
+	assert.Equal(s.T(), "sk-ant-stored", cred.APIKey)
+	assert.Equal(s.T(), auth.CredentialsFile, cred.Source)
+
+	// Environment key beats the stored key
+	s.T().Setenv(envName, "sk-ant-env")
+
+	cred, err = auth.Resolve(path, envName, auth.MethodAuto)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "sk-ant-env", cred.APIKey)
+	assert.Equal(s.T(), envName, cred.Source)
+
+	// A stored oauth preference ignores both keys
+	require.NoError(s.T(), auth.SaveCredentials(&auth.Credentials{APIKey: "sk-ant-stored", Method: auth.MethodOAuth}, path))
+
+	cred, err = auth.Resolve(path, envName, auth.MethodAuto)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), auth.MethodOAuth, cred.Method)
+	assert.Empty(s.T(), cred.APIKey)
+}
+
+// TestResolveRequiresAPIKey verifies that the api-key method never falls back to OAuth
+func (s *AuthTestSuite) TestResolveRequiresAPIKey() {
+	path := filepath.Join(s.tmpDir, auth.CredentialsFile)
+
+	_, err := auth.Resolve(path, "GIC_TEST_MISSING_KEY", auth.MethodAPIKey)
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "GIC_TEST_MISSING_KEY")
+}
+
+// TestParseMethod verifies credential method names
+func (s *AuthTestSuite) TestParseMethod() {
+	method, err := auth.ParseMethod("")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), auth.MethodAuto, method)
+
+	method, err = auth.ParseMethod("api-key")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), auth.MethodAPIKey, method)
+
+	_, err = auth.ParseMethod("password")
+	assert.Error(s.T(), err)
+}
+
+// TestMaskKey verifies that displayed keys hide their secret part
+func (s *AuthTestSuite) TestMaskKey() {
+	assert.Equal(s.T(), "sk-ant-…wxyz", auth.MaskKey("sk-ant-REDACTED"))
+	assert.Equal(s.T(), "****", auth.MaskKey("short"))
+}
+
 // TestSuite runs the auth integration test suite
 func TestAuthIntegration(t *testing.T) {
 	suite.Run(t, new(AuthTestSuite))
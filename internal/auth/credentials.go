@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Method is a way of authenticating with Anthropic.
+type Method string
+
+const (
+	// MethodAuto picks the first available credential in precedence order.
+	MethodAuto Method = "auto"
+	// MethodOAuth uses the claude.ai OAuth token.
+	MethodOAuth Method = "oauth"
+	// MethodAPIKey uses a Console API key.
+	MethodAPIKey Method = "api-key"
+)
+
+// ParseMethod validates a method name. An empty name means MethodAuto.
+func ParseMethod(name string) (Method, error) {
+	switch Method(name) {
+	case "", MethodAuto:
+		return MethodAuto, nil
+	case MethodOAuth, MethodAPIKey:
+		return Method(name), nil
+	default:
+		return "", fmt.Errorf("unknown auth method %q (expected auto, oauth or api-key)", name)
+	}
+}
+
+const (
+	// DefaultAPIKeyEnv is the environment variable checked for an API key.
+	DefaultAPIKeyEnv = "ANTHROPIC_API_KEY"
+	// CredentialsFile is the name of the stored credentials file, kept next
+	// to tokens.json.
+	CredentialsFile = "credentials.json"
+)
+
+// Credentials holds the stored API key and the preferred method.
+type Credentials struct {
+	APIKey string `json:"api_key,omitempty"`
+	Method Method `json:"method,omitempty"`
+}
+
+// LoadCredentials reads stored credentials from disk. A missing file yields
+// empty credentials.
+func LoadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Credentials{}, nil
+		}
+
+		return nil, err
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+
+	return &creds, nil
+}
+
+// SaveCredentials writes credentials to disk.
+func SaveCredentials(creds *Credentials, path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Credential is the credential selected for a run.
+type Credential struct {
+	// Method is MethodOAuth or MethodAPIKey.
+	Method Method
+	// Source describes where the credential comes from.
+	Source string
+	// APIKey is set for MethodAPIKey.
+	APIKey string
+}
+
+// Resolve selects the credential to use. An API key from the apiKeyEnv
+// environment variable (ANTHROPIC_API_KEY when empty) wins over the stored
+// API key, which wins over the OAuth token. method restricts the choice; with
+// MethodAuto the method stored in the credentials file applies.
+func Resolve(credentialsPath, apiKeyEnv string, method Method) (*Credential, error) {
+	creds, err := LoadCredentials(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+
+	if method == "" || method == MethodAuto {
+		method = creds.Method
+	}
+
+	if apiKeyEnv == "" {
+		apiKeyEnv = DefaultAPIKeyEnv
+	}
+
+	if method != MethodOAuth {
+		if key := os.Getenv(apiKeyEnv); key != "" {
+			return &Credential{Method: MethodAPIKey, Source: apiKeyEnv, APIKey: key}, nil
+		}
+
+		if creds.APIKey != "" {
+			return &Credential{Method: MethodAPIKey, Source: filepath.Base(credentialsPath), APIKey: creds.APIKey}, nil
+		}
+
+		if method == MethodAPIKey {
+			return nil, fmt.Errorf("no API key found: set %s or run 'gic auth api-key'", apiKeyEnv)
+		}
+	}
+
+	return &Credential{Method: MethodOAuth, Source: "tokens.json"}, nil
+}
+
+// MaskKey shortens an API key for display.
+func MaskKey(key string) string {
+	if len(key) <= 12 {
+		return "****"
+	}
+
+	return key[:7] + "…" + key[len(key)-4:]
+}
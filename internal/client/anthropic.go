@@ -0,0 +1,251 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// oauthSystemPrompt must lead the system prompt of requests made with a
+// claude.ai OAuth token.
+const oauthSystemPrompt = "You are Claude Code, Anthropic's official CLI for Claude."
+
+// Anthropic is a Provider backed by the Anthropic Messages API.
+type Anthropic struct {
+	client    anthropic.Client
+	system    []anthropic.TextBlockParam
+	retry     retrier
+	maxTokens int64
+	usageMeter
+}
+
+// NewAnthropicOAuth creates an Anthropic provider that authenticates with a
+// claude.ai OAuth access token. cfg.BaseURL is ignored.
+func NewAnthropicOAuth(accessToken string, cfg ProviderConfig) *Anthropic {
+	httpClient := &http.Client{
+		Transport: &oauthTransport{token: accessToken},
+	}
+
+	// The token must only ever be sent to Anthropic
+	cfg.BaseURL = ""
+
+	p := newAnthropic(cfg, option.WithHTTPClient(httpClient))
+	p.system = []anthropic.TextBlockParam{{Type: "text", Text: oauthSystemPrompt}}
+
+	return p
+}
+
+// NewAnthropicAPIKey creates an Anthropic provider that authenticates with a
+// Console API key.
+func NewAnthropicAPIKey(apiKey string, cfg ProviderConfig) *Anthropic {
+	return newAnthropic(cfg, option.WithAPIKey(apiKey))
+}
+
+func newAnthropic(cfg ProviderConfig, opts ...option.RequestOption) *Anthropic {
+	// Retries are handled by retrier so they can be reported and fall back
+	opts = append(opts, option.WithMaxRetries(0))
+
+	if cfg.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.BaseURL))
+	}
+
+	if cfg.Timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.Timeout))
+	}
+
+	model := cfg.Model
+	if model == "" {
+		model = DefaultModel
+	}
+
+	return &Anthropic{
+		client:    anthropic.NewClient(opts...),
+		retry:     retrier{policy: cfg.Retry.withDefaults(), model: model, fallback: cfg.FallbackModel},
+		maxTokens: cfg.MaxTokens,
+	}
+}
+
+// Ask sends a conversation to Claude and returns the response text.
+func (p *Anthropic) Ask(ctx context.Context, messages []Message) (string, error) {
+	message, err := p.send(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var response string
+	for _, block := range message.Content {
+		response += block.Text
+	}
+
+	return response, nil
+}
+
+// AskStructured sends a conversation to Claude, forces it to answer through
+// tool and returns the raw JSON input Claude produced for it.
+func (p *Anthropic) AskStructured(ctx context.Context, messages []Message, tool Tool) (json.RawMessage, error) {
+	message, err := p.send(ctx, messages, &tool)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, block := range message.Content {
+		if block.Type == "tool_use" && block.Name == tool.Name {
+			return block.Input, nil
+		}
+	}
+
+	return nil, fmt.Errorf("response did not include a %s answer", tool.Name)
+}
+
+// AskStream sends a conversation to Claude over the streaming API, calling
+// onText with the response text received so far as tokens arrive.
+// Cancelling ctx aborts the request.
+func (p *Anthropic) AskStream(ctx context.Context, messages []Message, onText func(string)) (string, error) {
+	var response string
+
+	err := p.retry.do(ctx, func(model string) error {
+		var err error
+
+		response, err = p.stream(ctx, model, messages, onText)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("API call failed: %w", err)
+	}
+
+	return response, nil
+}
+
+// stream performs a single streaming Messages API call.
+func (p *Anthropic) stream(ctx context.Context, model string, messages []Message, onText func(string)) (string, error) {
+	stream := p.client.Messages.NewStreaming(ctx, p.params(model, messages, nil))
+
+	defer func() { _ = stream.Close() }()
+
+	var (
+		response strings.Builder
+		message  anthropic.Message
+	)
+
+	// Usage is reported once the stream completes
+	defer func() { p.add(message.Usage.InputTokens, message.Usage.OutputTokens) }()
+
+	for stream.Next() {
+		current := stream.Current()
+		if err := message.Accumulate(current); err != nil {
+			return "", err
+		}
+
+		event, ok := current.AsAny().(anthropic.ContentBlockDeltaEvent)
+		if !ok {
+			continue
+		}
+
+		if delta, ok := event.Delta.AsAny().(anthropic.TextDelta); ok && delta.Text != "" {
+			response.WriteString(delta.Text)
+
+			if onText != nil {
+				onText(response.String())
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return "", err
+	}
+
+	return response.String(), nil
+}
+
+// send performs a Messages API call, optionally forcing a tool answer, and
+// retries transient failures.
+func (p *Anthropic) send(ctx context.Context, messages []Message, tool *Tool) (*anthropic.Message, error) {
+	var message *anthropic.Message
+
+	err := p.retry.do(ctx, func(model string) error {
+		var err error
+
+		message, err = p.client.Messages.New(ctx, p.params(model, messages, tool))
+		if err == nil {
+			p.add(message.Usage.InputTokens, message.Usage.OutputTokens)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("API call failed: %w", err)
+	}
+
+	return message, nil
+}
+
+// CountTokens returns the exact number of input tokens the conversation
+// would use with the primary model.
+func (p *Anthropic) CountTokens(ctx context.Context, messages []Message) (int, error) {
+	params := p.params(p.retry.model, messages, nil)
+
+	count, err := p.client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{
+		Model:    params.Model,
+		Messages: params.Messages,
+		System:   anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("token count failed: %w", err)
+	}
+
+	return int(count.InputTokens), nil
+}
+
+// params builds a Messages API request, optionally forcing a tool answer.
+func (p *Anthropic) params(model string, messages []Message, tool *Tool) anthropic.MessageNewParams {
+	params := anthropic.MessageNewParams{
+		Model:     anthropic.Model(model),
+		MaxTokens: p.maxTokens,
+		System:    p.system,
+	}
+
+	for _, m := range messages {
+		if m.Assistant {
+			params.Messages = append(params.Messages, anthropic.NewAssistantMessage(anthropic.NewTextBlock(m.Text)))
+		} else {
+			params.Messages = append(params.Messages, anthropic.NewUserMessage(anthropic.NewTextBlock(m.Text)))
+		}
+	}
+
+	if tool != nil {
+		schema := anthropic.ToolInputSchemaParam{
+			Properties: tool.Properties,
+			Required:   tool.Required,
+		}
+
+		toolParam := anthropic.ToolUnionParamOfTool(schema, tool.Name)
+		toolParam.OfTool.Description = anthropic.String(tool.Description)
+
+		params.Tools = []anthropic.ToolUnionParam{toolParam}
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(tool.Name)
+	}
+
+	return params
+}
+
+// oauthTransport implements http.RoundTripper to add OAuth headers.
+type oauthTransport struct {
+	token string
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	req.Header.Del("x-api-key")
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("anthropic-beta", "oauth-2025-04-20")
+
+	return http.DefaultTransport.RoundTrip(req)
+}
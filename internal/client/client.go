@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -115,51 +117,167 @@ func TestWithOAuth(accessToken string) error {
 	return nil
 }
 
-// Ask sends a prompt to Claude and returns the response text.
-func Ask(accessToken, prompt string) (string, error) {
-	httpClient := &http.Client{
-		Transport: &oauthTransport{token: accessToken},
-	}
+// Message is a single turn in a conversation with Claude.
+type Message struct {
+	// Assistant marks turns written by Claude; all others are user turns.
+	Assistant bool
+	Text      string
+}
 
-	client := anthropic.NewClient(option.WithHTTPClient(httpClient))
+// Tool describes a structured answer Claude must return. Properties and
+// Required form the JSON schema of the answer object.
+type Tool struct {
+	Name        string
+	Description string
+	Properties  map[string]any
+	Required    []string
+}
 
-	message, err := client.Messages.New(context.Background(), anthropic.MessageNewParams{
-		Model:     "claude-sonnet-4-5",
-		MaxTokens: 2048,
-		System: []anthropic.TextBlockParam{
-			{
-				Type: "text",
-				Text: "You are Claude Code, Anthropic's official CLI for Claude.",
-			},
-		},
-		Messages: []anthropic.MessageParam{
-			anthropic.NewUserMessage(anthropic.NewTextBlock(prompt)),
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("API call failed: %w", err)
+// Provider is a language model backend that answers prompts.
+type Provider interface {
+	// Ask sends a conversation and returns the response text.
+	Ask(ctx context.Context, messages []Message) (string, error)
+	// AskStructured sends a conversation, forces the model to answer through
+	// tool and returns the raw JSON arguments it produced.
+	AskStructured(ctx context.Context, messages []Message, tool Tool) (json.RawMessage, error)
+}
+
+// Streamer is implemented by providers that can stream responses while they
+// are generated.
+type Streamer interface {
+	// AskStream sends a conversation and calls onText with the response text
+	// received so far each time more arrives. Cancelling ctx aborts the
+	// request.
+	AskStream(ctx context.Context, messages []Message, onText func(string)) (string, error)
+}
+
+// AskStream streams a response from provider when it implements Streamer.
+// Other providers are asked for the whole response, which is passed to onText
+// once it arrives.
+func AskStream(ctx context.Context, provider Provider, messages []Message, onText func(string)) (string, error) {
+	if streamer, ok := provider.(Streamer); ok {
+		return streamer.AskStream(ctx, messages, onText)
 	}
 
-	var response string
-	for _, block := range message.Content {
-		response += block.Text
+	response, err := provider.Ask(ctx, messages)
+	if err == nil && onText != nil {
+		onText(response)
 	}
 
-	return response, nil
+	return response, err
+}
+
+// Provider names accepted by NewProvider.
+const (
+	ProviderAnthropic       = "anthropic"
+	ProviderAnthropicAPIKey = "anthropic-api-key"
+	ProviderOpenAI          = "openai"
+)
+
+const (
+	// DefaultModel is the Anthropic model used when none is configured.
+	DefaultModel = "claude-sonnet-4-5"
+	// DefaultMaxTokens caps responses when no limit is configured.
+	DefaultMaxTokens = 2048
+)
+
+// ProviderConfig selects and tunes a provider.
+type ProviderConfig struct {
+	// Name is one of the Provider* constants; empty means ProviderAnthropic.
+	Name string
+	// Model overrides the provider's default model.
+	Model string
+	// MaxTokens caps the length of responses.
+	MaxTokens int64
+	// BaseURL overrides the API endpoint, e.g. a local OpenAI-compatible server.
+	BaseURL string
+	// APIKeyEnv names the environment variable holding the API key for the
+	// anthropic-api-key and openai providers.
+	APIKeyEnv string
+	// APIKey makes the Anthropic providers authenticate with this key instead
+	// of reading APIKeyEnv or using an OAuth token.
+	APIKey string
+	// Timeout bounds each API request; zero keeps the backend's default.
+	Timeout time.Duration
+	// Retry controls how transient failures are retried.
+	Retry RetryPolicy
+	// FallbackModel is tried once retries with Model are exhausted.
+	FallbackModel string
+	// ContextWindowTokens overrides the model's known context window.
+	ContextWindowTokens int
 }
 
-// oauthTransport implements http.RoundTripper to add OAuth headers.
-type oauthTransport struct {
-	token string
+// IsAnthropic reports whether the provider talks to the Anthropic API.
+func (c ProviderConfig) IsAnthropic() bool {
+	return c.Name == "" || c.Name == ProviderAnthropic || c.Name == ProviderAnthropicAPIKey
 }
 
-func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req = req.Clone(req.Context())
+// NeedsOAuth reports whether the provider authenticates with a claude.ai
+// OAuth token.
+func (c ProviderConfig) NeedsOAuth() bool {
+	return (c.Name == "" || c.Name == ProviderAnthropic) && c.APIKey == ""
+}
 
-	req.Header.Del("x-api-key")
-	req.Header.Set("Authorization", "Bearer "+t.token)
-	req.Header.Set("anthropic-version", "2023-06-01")
-	req.Header.Set("anthropic-beta", "oauth-2025-04-20")
+// NewProvider builds the provider selected by cfg. accessToken is only used
+// by the OAuth-based anthropic provider.
+func NewProvider(cfg ProviderConfig, accessToken string) (Provider, error) {
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+
+	switch cfg.Name {
+	case "", ProviderAnthropic:
+		if cfg.APIKey != "" {
+			return NewAnthropicAPIKey(cfg.APIKey, cfg), nil
+		}
+
+		return NewAnthropicOAuth(accessToken, cfg), nil
+	case ProviderAnthropicAPIKey:
+		apiKey := cfg.APIKey
+		if apiKey == "" {
+			var err error
+
+			apiKey, err = apiKeyFromEnv(cfg.APIKeyEnv, "ANTHROPIC_API_KEY")
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return NewAnthropicAPIKey(apiKey, cfg), nil
+	case ProviderOpenAI:
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("the openai provider requires a model")
+		}
+
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = defaultOpenAIBaseURL
+		}
+
+		// Local servers usually accept any key, so a missing one is not an error
+		apiKey, _ := apiKeyFromEnv(cfg.APIKeyEnv, "OPENAI_API_KEY")
+
+		return NewOpenAI(apiKey, cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (expected %s, %s or %s)", cfg.Name, ProviderAnthropic, ProviderAnthropicAPIKey, ProviderOpenAI)
+	}
+}
+
+// apiKeyFromEnv reads an API key from the named environment variable, or
+// from fallback when no name is given.
+func apiKeyFromEnv(name, fallback string) (string, error) {
+	if name == "" {
+		name = fallback
+	}
+
+	apiKey := os.Getenv(name)
+	if apiKey == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	return apiKey, nil
+}
 
-	return http.DefaultTransport.RoundTrip(req)
+// Ask sends a prompt to Claude with an OAuth token and returns the response text.
+func Ask(ctx context.Context, accessToken, prompt string) (string, error) {
+	return NewAnthropicOAuth(accessToken, ProviderConfig{MaxTokens: DefaultMaxTokens}).Ask(ctx, []Message{{Text: prompt}})
 }
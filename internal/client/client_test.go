@@ -1,12 +1,14 @@
 package client_test
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"gic/internal/client"
 
@@ -72,11 +74,11 @@ func (s *ClientTestSuite) TestAsk() {
 	// This test documents the API contract.
 
 	// Test with empty token (should fail)
-	_, err := client.Ask("", "test prompt")
+	_, err := client.Ask(context.Background(), "", "test prompt")
 	assert.Error(s.T(), err)
 
 	// Test with fake token (will fail to authenticate)
-	_, err = client.Ask("fake-token", "test prompt")
+	_, err = client.Ask(context.Background(), "fake-token", "test prompt")
 	assert.Error(s.T(), err)
 }
 
@@ -114,7 +116,7 @@ func (s *ClientTestSuite) TestAPIKeyValidation() {
 	// This test documents expected behavior
 
 	// Empty API key should fail
-	_, err := client.Ask("", "test prompt")
+	_, err := client.Ask(context.Background(), "", "test prompt")
 	assert.Error(s.T(), err)
 }
 
@@ -125,7 +127,7 @@ func (s *ClientTestSuite) TestPromptValidation() {
 
 	// Test that we can construct a call with empty prompt
 	// (API will likely reject it, but client doesn't pre-validate)
-	_, err := client.Ask("fake-token", "")
+	_, err := client.Ask(context.Background(), "fake-token", "")
 	assert.Error(s.T(), err, "Expected error from API")
 }
 
@@ -283,6 +285,336 @@ func (s *ClientTestSuite) TestClientBehaviorDocumentation() {
 	s.T().Log("Client behavior documented")
 }
 
+// TestOpenAIProvider verifies requests to an OpenAI-compatible endpoint
+func (s *ClientTestSuite) TestOpenAIProvider() {
+	var reqBody map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), "/v1/chat/completions", r.URL.Path)
+		assert.Equal(s.T(), "Bearer local-key", r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), json.Unmarshal(body, &reqBody))
+
+		message := map[string]interface{}{"role": "assistant", "content": "Add feature"}
+		if _, ok := reqBody["tools"]; ok {
+			message = map[string]interface{}{
+				"role": "assistant",
+				"tool_calls": []map[string]interface{}{
+					{"type": "function", "function": map[string]string{"name": "submit", "arguments": `{"messages":["a"]}`}},
+				},
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": message}},
+		})
+	}))
+	defer server.Close()
+
+	provider := client.NewOpenAI("local-key", client.ProviderConfig{Model: "llama3", MaxTokens: 512, BaseURL: server.URL + "/v1/"})
+
+	text, err := provider.Ask(context.Background(), []client.Message{{Text: "hi"}, {Assistant: true, Text: "draft"}, {Text: "again"}})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Add feature", text)
+	assert.Equal(s.T(), "llama3", reqBody["model"])
+	assert.EqualValues(s.T(), 512, reqBody["max_tokens"])
+
+	messages := reqBody["messages"].([]interface{})
+	require.Len(s.T(), messages, 3)
+	assert.Equal(s.T(), "assistant", messages[1].(map[string]interface{})["role"])
+
+	raw, err := provider.AskStructured(context.Background(), []client.Message{{Text: "hi"}}, client.Tool{Name: "submit"})
+	require.NoError(s.T(), err)
+	assert.JSONEq(s.T(), `{"messages":["a"]}`, string(raw))
+	assert.NotNil(s.T(), reqBody["tool_choice"])
+}
+
+// TestOpenAIProviderPlainJSON verifies the fallback for servers without tool calling
+func (s *ClientTestSuite) TestOpenAIProviderPlainJSON() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": "Sure: {\"messages\": [\"b\"]}"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	provider := client.NewOpenAI("", client.ProviderConfig{Model: "llama3", BaseURL: server.URL})
+
+	raw, err := provider.AskStructured(context.Background(), []client.Message{{Text: "hi"}}, client.Tool{Name: "submit"})
+	require.NoError(s.T(), err)
+	assert.JSONEq(s.T(), `{"messages": ["b"]}`, string(raw))
+}
+
+// TestAnthropicAPIKeyProvider verifies API key requests to the Messages API
+func (s *ClientTestSuite) TestAnthropicAPIKeyProvider() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), "sk-ant-test", r.Header.Get("x-api-key"))
+		assert.Empty(s.T(), r.Header.Get("Authorization"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(s.T(), err)
+
+		var reqBody map[string]interface{}
+		require.NoError(s.T(), json.Unmarshal(body, &reqBody))
+		assert.Equal(s.T(), "claude-haiku-4-5", reqBody["model"])
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":      "msg_123",
+			"type":    "message",
+			"role":    "assistant",
+			"content": []map[string]string{{"type": "text", "text": "Fix typo"}},
+			"model":   "claude-haiku-4-5",
+			"usage":   map[string]int{"input_tokens": 10, "output_tokens": 2},
+		})
+	}))
+	defer server.Close()
+
+	provider := client.NewAnthropicAPIKey("sk-ant-test", client.ProviderConfig{Model: "claude-haiku-4-5", MaxTokens: 100, BaseURL: server.URL})
+
+	text, err := provider.Ask(context.Background(), []client.Message{{Text: "hi"}})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Fix typo", text)
+	assert.Equal(s.T(), client.Usage{InputTokens: 10, OutputTokens: 2}, provider.Usage())
+}
+
+// TestTokenCounting verifies that the local estimate is calibrated against
+// the count_tokens endpoint
+func (s *ClientTestSuite) TestTokenCounting() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), "/v1/messages/count_tokens", r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"input_tokens": 40}`)
+	}))
+	defer server.Close()
+
+	provider := client.NewAnthropicAPIKey("sk-ant-test", client.ProviderConfig{Model: "claude-haiku-4-5", MaxTokens: 100, BaseURL: server.URL})
+
+	count, err := provider.CountTokens(context.Background(), []client.Message{{Text: "hi"}})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 40, count)
+
+	tok := client.NewTokenizer()
+	text := "func main() {\n\tfmt.Println(\"hello\")\n}\n"
+	estimate := tok.Count(text)
+	assert.Greater(s.T(), estimate, 5)
+
+	assert.Equal(s.T(), 40, tok.Calibrate(context.Background(), provider, text))
+	assert.InDelta(s.T(), 40, tok.Count(text), 1)
+	assert.InDelta(s.T(), 80, tok.Count(text+text), 2)
+
+	// Without an exact counter the estimate is used as is
+	openai := client.NewOpenAI("key", client.ProviderConfig{Model: "gpt-4o-mini", MaxTokens: 100, BaseURL: server.URL})
+	assert.Equal(s.T(), estimate, client.NewTokenizer().Calibrate(context.Background(), openai, text))
+}
+
+// TestAnthropicStreaming verifies that streamed text deltas reach the callback
+func (s *ClientTestSuite) TestAnthropicStreaming() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(s.T(), err)
+
+		var reqBody map[string]interface{}
+		require.NoError(s.T(), json.Unmarshal(body, &reqBody))
+		assert.Equal(s.T(), true, reqBody["stream"])
+
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_123","type":"message","role":"assistant","content":[],"model":"claude-haiku-4-5","usage":{"input_tokens":10,"output_tokens":0}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Fix "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"typo"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_stop"}`,
+		}
+
+		for _, event := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			require.NoError(s.T(), json.Unmarshal([]byte(event), &typed))
+
+			_, _ = io.WriteString(w, "event: "+typed.Type+"\ndata: "+event+"\n\n")
+		}
+	}))
+	defer server.Close()
+
+	provider := client.NewAnthropicAPIKey("sk-ant-test", client.ProviderConfig{Model: "claude-haiku-4-5", MaxTokens: 100, BaseURL: server.URL})
+
+	var seen []string
+
+	text, err := client.AskStream(context.Background(), provider, []client.Message{{Text: "hi"}}, func(text string) {
+		seen = append(seen, text)
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Fix typo", text)
+	assert.Equal(s.T(), []string{"Fix ", "Fix typo"}, seen)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = client.AskStream(ctx, provider, []client.Message{{Text: "hi"}}, nil)
+	assert.ErrorIs(s.T(), err, context.Canceled)
+}
+
+// TestRetryTransientFailures verifies that overload and rate-limit errors are
+// retried with backoff, reported, and finally handed to the fallback model
+func (s *ClientTestSuite) TestRetryTransientFailures() {
+	var models []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(s.T(), err)
+
+		var reqBody map[string]interface{}
+		require.NoError(s.T(), json.Unmarshal(body, &reqBody))
+
+		model := reqBody["model"].(string)
+		models = append(models, model)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case model == "claude-busy":
+			w.Header().Set("retry-after-ms", "1")
+			w.WriteHeader(529)
+			_, _ = io.WriteString(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+		case model == "claude-invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`)
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":      "msg_123",
+				"type":    "message",
+				"role":    "assistant",
+				"content": []map[string]string{{"type": "text", "text": "Fix typo"}},
+				"model":   model,
+				"usage":   map[string]int{"input_tokens": 10, "output_tokens": 2},
+			})
+		}
+	}))
+	defer server.Close()
+
+	var retries []client.Retry
+
+	ctx := client.WithRetryNotify(context.Background(), func(r client.Retry) {
+		retries = append(retries, r)
+	})
+
+	provider := client.NewAnthropicAPIKey("sk-ant-test", client.ProviderConfig{
+		Model:         "claude-busy",
+		FallbackModel: "claude-haiku-4-5",
+		MaxTokens:     100,
+		BaseURL:       server.URL,
+		Retry:         client.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	})
+
+	text, err := provider.Ask(ctx, []client.Message{{Text: "hi"}})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Fix typo", text)
+	assert.Equal(s.T(), []string{"claude-busy", "claude-busy", "claude-busy", "claude-haiku-4-5"}, models)
+
+	require.Len(s.T(), retries, 3)
+	assert.Equal(s.T(), 1, retries[0].Attempt)
+	assert.Contains(s.T(), retries[0].String(), "overloaded")
+	assert.Contains(s.T(), retries[0].String(), "attempt 2/3")
+	assert.True(s.T(), retries[2].Fallback)
+	assert.Equal(s.T(), "claude-haiku-4-5", retries[2].Model)
+
+	// Permanent failures are not retried
+	models = nil
+
+	provider = client.NewAnthropicAPIKey("sk-ant-test", client.ProviderConfig{
+		Model:     "claude-invalid",
+		MaxTokens: 100,
+		BaseURL:   server.URL,
+		Retry:     client.RetryPolicy{BaseDelay: time.Millisecond},
+	})
+
+	_, err = provider.Ask(context.Background(), []client.Message{{Text: "hi"}})
+	require.Error(s.T(), err)
+	assert.Len(s.T(), models, 1)
+}
+
+// TestOpenAIRetry verifies that rate-limited OpenAI-compatible requests are
+// retried and that disabled retries fail fast
+func (s *ClientTestSuite) TestOpenAIRetry() {
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if requests == 1 {
+			w.Header().Set("Retry-After-Ms", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, `{"error":"slow down"}`)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": "Add feature"}}},
+		})
+	}))
+	defer server.Close()
+
+	provider := client.NewOpenAI("", client.ProviderConfig{Model: "llama3", BaseURL: server.URL, Retry: client.RetryPolicy{BaseDelay: time.Millisecond}})
+
+	text, err := provider.Ask(context.Background(), []client.Message{{Text: "hi"}})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Add feature", text)
+	assert.Equal(s.T(), 2, requests)
+
+	requests = 0
+
+	provider = client.NewOpenAI("", client.ProviderConfig{Model: "llama3", BaseURL: server.URL, Retry: client.RetryPolicy{MaxRetries: -1}})
+
+	_, err = provider.Ask(context.Background(), []client.Message{{Text: "hi"}})
+	require.Error(s.T(), err)
+
+	var statusErr *client.StatusError
+	require.ErrorAs(s.T(), err, &statusErr)
+	assert.Equal(s.T(), http.StatusTooManyRequests, statusErr.StatusCode)
+	assert.Equal(s.T(), 1, requests)
+}
+
+// TestNewProvider verifies provider selection
+func (s *ClientTestSuite) TestNewProvider() {
+	provider, err := client.NewProvider(client.ProviderConfig{}, "token")
+	require.NoError(s.T(), err)
+	assert.IsType(s.T(), &client.Anthropic{}, provider)
+	assert.True(s.T(), client.ProviderConfig{}.NeedsOAuth())
+
+	s.T().Setenv("GIC_TEST_KEY", "sk-ant-test")
+
+	provider, err = client.NewProvider(client.ProviderConfig{Name: client.ProviderAnthropicAPIKey, APIKeyEnv: "GIC_TEST_KEY"}, "")
+	require.NoError(s.T(), err)
+	assert.IsType(s.T(), &client.Anthropic{}, provider)
+
+	_, err = client.NewProvider(client.ProviderConfig{Name: client.ProviderAnthropicAPIKey, APIKeyEnv: "GIC_TEST_MISSING_KEY"}, "")
+	assert.Error(s.T(), err)
+
+	_, err = client.NewProvider(client.ProviderConfig{Name: client.ProviderOpenAI}, "")
+	assert.Error(s.T(), err, "openai requires a model")
+
+	provider, err = client.NewProvider(client.ProviderConfig{Name: client.ProviderOpenAI, Model: "llama3", BaseURL: "http://localhost:8080/v1"}, "")
+	require.NoError(s.T(), err)
+	assert.IsType(s.T(), &client.OpenAI{}, provider)
+	assert.False(s.T(), client.ProviderConfig{Name: client.ProviderOpenAI}.NeedsOAuth())
+
+	_, err = client.NewProvider(client.ProviderConfig{Name: "bogus"}, "")
+	assert.Error(s.T(), err)
+}
+
 // TestSuite runs the client integration test suite
 func TestClientIntegration(t *testing.T) {
 	suite.Run(t, new(ClientTestSuite))
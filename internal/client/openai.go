@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI is a Provider for any OpenAI-compatible chat completions endpoint,
+// including local llama.cpp and Ollama servers.
+type OpenAI struct {
+	httpClient *http.Client
+	baseURL    string
+	apiKey     string
+	retry      retrier
+	maxTokens  int64
+	usageMeter
+}
+
+// NewOpenAI creates a provider for the chat completions API at cfg.BaseURL.
+// apiKey may be empty for servers that do not require one.
+func NewOpenAI(apiKey string, cfg ProviderConfig) *OpenAI {
+	return &OpenAI{
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		baseURL:    strings.TrimRight(cfg.BaseURL, "/"),
+		apiKey:     apiKey,
+		retry:      retrier{policy: cfg.Retry.withDefaults(), model: cfg.Model, fallback: cfg.FallbackModel},
+		maxTokens:  cfg.MaxTokens,
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIRequest struct {
+	Model      string          `json:"model"`
+	MaxTokens  int64           `json:"max_tokens,omitempty"`
+	Messages   []openAIMessage `json:"messages"`
+	Tools      []openAITool    `json:"tools,omitempty"`
+	ToolChoice any             `json:"tool_choice,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Ask sends a conversation and returns the response text.
+func (p *OpenAI) Ask(ctx context.Context, messages []Message) (string, error) {
+	resp, err := p.send(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// AskStructured sends a conversation with tool as the required function call
+// and returns its JSON arguments. Servers that ignore tool_choice often answer
+// with the JSON object as plain text, so that is accepted too.
+func (p *OpenAI) AskStructured(ctx context.Context, messages []Message, tool Tool) (json.RawMessage, error) {
+	resp, err := p.send(ctx, messages, &tool)
+	if err != nil {
+		return nil, err
+	}
+
+	message := resp.Choices[0].Message
+
+	for _, call := range message.ToolCalls {
+		if call.Function.Name == tool.Name {
+			return json.RawMessage(call.Function.Arguments), nil
+		}
+	}
+
+	start := strings.Index(message.Content, "{")
+	end := strings.LastIndex(message.Content, "}")
+
+	if start >= 0 && end > start && json.Valid([]byte(message.Content[start:end+1])) {
+		return json.RawMessage(message.Content[start : end+1]), nil
+	}
+
+	return nil, fmt.Errorf("response did not include a %s answer", tool.Name)
+}
+
+// send performs a chat completions call, optionally forcing a function call,
+// and retries transient failures.
+func (p *OpenAI) send(ctx context.Context, messages []Message, tool *Tool) (*openAIResponse, error) {
+	var result *openAIResponse
+
+	err := p.retry.do(ctx, func(model string) error {
+		var err error
+
+		result, err = p.complete(ctx, model, messages, tool)
+		if err == nil {
+			p.add(result.Usage.PromptTokens, result.Usage.CompletionTokens)
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("API call failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// complete performs a single chat completions call.
+func (p *OpenAI) complete(ctx context.Context, model string, messages []Message, tool *Tool) (*openAIResponse, error) {
+	reqBody := openAIRequest{
+		Model:     model,
+		MaxTokens: p.maxTokens,
+	}
+
+	for _, m := range messages {
+		role := "user"
+		if m.Assistant {
+			role = "assistant"
+		}
+
+		reqBody.Messages = append(reqBody.Messages, openAIMessage{Role: role, Content: m.Text})
+	}
+
+	if tool != nil {
+		reqBody.Tools = []openAITool{{
+			Type: "function",
+			Function: openAIFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters: map[string]any{
+					"type":       "object",
+					"properties": tool.Properties,
+					"required":   tool.Required,
+				},
+			},
+		}}
+		reqBody.ToolChoice = map[string]any{
+			"type":     "function",
+			"function": map[string]string{"name": tool.Name},
+		}
+	}
+
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.baseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(body),
+			RetryAfter: retryAfter(resp.Header),
+		}
+	}
+
+	var result openAIResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if len(result.Choices) == 0 {
+		return nil, fmt.Errorf("response contained no choices")
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,344 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+const (
+	// DefaultMaxRetries is how often a transient failure is retried per model
+	// when none is configured.
+	DefaultMaxRetries = 3
+	// retryBaseDelay is the wait before the first retry; later waits double.
+	retryBaseDelay = time.Second
+	// retryMaxDelay caps the backoff between attempts.
+	retryMaxDelay = 30 * time.Second
+	// retryAfterLimit caps how long a server-requested wait is honoured.
+	retryAfterLimit = 2 * time.Minute
+)
+
+// RetryPolicy controls how transient API failures such as rate limits or
+// overload are retried. Zero values select the defaults.
+type RetryPolicy struct {
+	// MaxRetries is how often a failed request is repeated per model; zero
+	// means DefaultMaxRetries and a negative value disables retries.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry; later waits double up
+	// to MaxDelay, with jitter.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between attempts.
+	MaxDelay time.Duration
+}
+
+// withDefaults fills in unset fields.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	switch {
+	case p.MaxRetries == 0:
+		p.MaxRetries = DefaultMaxRetries
+	case p.MaxRetries < 0:
+		p.MaxRetries = 0
+	}
+
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = retryBaseDelay
+	}
+
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = retryMaxDelay
+	}
+
+	return p
+}
+
+// backoff returns the jittered wait before retry number attempt (starting
+// at 1), or the server's retry-after hint when that is longer.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := p.BaseDelay << (attempt - 1)
+	if wait > p.MaxDelay || wait <= 0 {
+		wait = p.MaxDelay
+	}
+
+	// Full jitter over the upper half keeps clients from retrying in lockstep
+	if half := int64(wait / 2); half > 0 {
+		wait = time.Duration(half + rand.Int64N(half+1))
+	}
+
+	if retryAfter > wait {
+		wait = min(retryAfter, retryAfterLimit)
+	}
+
+	return wait
+}
+
+// Retry describes a failed request that is about to be repeated.
+type Retry struct {
+	// Attempt is the number of the failed attempt with Model, starting at 1.
+	Attempt int
+	// MaxAttempts is the number of attempts allowed per model.
+	MaxAttempts int
+	// Model is the model the next attempt uses.
+	Model string
+	// Fallback is set when retries are exhausted and the next attempt
+	// switches to the fallback model.
+	Fallback bool
+	// Wait is how long the client waits before the next attempt.
+	Wait time.Duration
+	// Err is the error of the failed attempt.
+	Err error
+}
+
+// String renders the retry for status lines, e.g.
+// "Claude is overloaded, retrying in 2s (attempt 2/4)".
+func (r Retry) String() string {
+	if r.Fallback {
+		return fmt.Sprintf("%s, switching to %s", failureReason(r.Err), r.Model)
+	}
+
+	return fmt.Sprintf("%s, retrying in %s (attempt %d/%d)", failureReason(r.Err), r.Wait.Round(time.Second), r.Attempt+1, r.MaxAttempts)
+}
+
+type retryNotifyKey struct{}
+
+// WithRetryNotify returns a context that makes providers call notify before
+// each retry of a request made with it.
+func WithRetryNotify(ctx context.Context, notify func(Retry)) context.Context {
+	return context.WithValue(ctx, retryNotifyKey{}, notify)
+}
+
+// notifyRetry reports r to the callback registered on ctx, if any.
+func notifyRetry(ctx context.Context, r Retry) {
+	if notify, ok := ctx.Value(retryNotifyKey{}).(func(Retry)); ok && notify != nil {
+		notify(r)
+	}
+}
+
+// retrier repeats requests that fail transiently, first with the primary
+// model and then with the fallback model.
+type retrier struct {
+	policy   RetryPolicy
+	model    string
+	fallback string
+}
+
+// do calls fn with each model in turn until it succeeds, retrying transient
+// failures with backoff. Permanent failures are returned immediately.
+func (r retrier) do(ctx context.Context, fn func(model string) error) error {
+	models := []string{r.model}
+	if r.fallback != "" && r.fallback != r.model {
+		models = append(models, r.fallback)
+	}
+
+	maxAttempts := r.policy.MaxRetries + 1
+
+	var err error
+
+	for i, model := range models {
+		for attempt := 1; ; attempt++ {
+			if err = fn(model); err == nil {
+				return nil
+			}
+
+			transient, retryAfter := retryable(err)
+			if !transient || ctx.Err() != nil {
+				return err
+			}
+
+			if attempt >= maxAttempts {
+				break
+			}
+
+			wait := r.policy.backoff(attempt, retryAfter)
+			notifyRetry(ctx, Retry{Attempt: attempt, MaxAttempts: maxAttempts, Model: model, Wait: wait, Err: err})
+
+			if sleepErr := sleep(ctx, wait); sleepErr != nil {
+				return sleepErr
+			}
+		}
+
+		if i+1 < len(models) {
+			notifyRetry(ctx, Retry{Attempt: maxAttempts, MaxAttempts: maxAttempts, Model: models[i+1], Fallback: true, Err: err})
+		}
+	}
+
+	return err
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// StatusError is an unsuccessful response from an OpenAI-compatible API.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter is the wait requested by the server, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s - %s", e.Status, e.Body)
+}
+
+// transientErrorTypes are Anthropic error types worth retrying.
+var transientErrorTypes = []string{"overloaded_error", "rate_limit_error", "api_error"}
+
+// retryable reports whether err is a transient failure and how long the
+// server asked to wait before retrying.
+func retryable(err error) (bool, time.Duration) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+
+		return transientStatus(apiErr.StatusCode) || isTransientType(anthropicErrorType(apiErr.RawJSON())), retryAfter(header)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return transientStatus(statusErr.StatusCode), statusErr.RetryAfter
+	}
+
+	// A host that does not resolve will not start resolving on a retry
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false, 0
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true, 0
+	}
+
+	// Errors sent as events in the middle of a stream are only available as text
+	for _, errType := range transientErrorTypes {
+		if strings.Contains(err.Error(), errType) {
+			return true, 0
+		}
+	}
+
+	return false, 0
+}
+
+// transientStatus reports whether an HTTP status is worth retrying.
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests, 529:
+		return true
+	default:
+		return code >= http.StatusInternalServerError
+	}
+}
+
+func isTransientType(errType string) bool {
+	for _, t := range transientErrorTypes {
+		if errType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// anthropicErrorType extracts error.type from an Anthropic error body.
+func anthropicErrorType(body string) string {
+	var parsed struct {
+		Error struct {
+			Type string `json:"type"`
+		} `json:"error"`
+	}
+
+	if json.Unmarshal([]byte(body), &parsed) != nil {
+		return ""
+	}
+
+	return parsed.Error.Type
+}
+
+// retryAfter parses the retry-after-ms or retry-after response headers.
+func retryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms > 0 {
+		return time.Duration(ms * float64(time.Millisecond))
+	}
+
+	value := header.Get("retry-after")
+	if value == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+
+	return 0
+}
+
+// failureReason describes a transient failure in a few words.
+func failureReason(err error) string {
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		switch anthropicErrorType(apiErr.RawJSON()) {
+		case "overloaded_error":
+			return "Claude is overloaded"
+		case "rate_limit_error":
+			return "rate limited"
+		}
+
+		return describeStatus(apiErr.StatusCode)
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return describeStatus(statusErr.StatusCode)
+	}
+
+	if err != nil && strings.Contains(err.Error(), "overloaded_error") {
+		return "Claude is overloaded"
+	}
+
+	return "request failed"
+}
+
+func describeStatus(code int) string {
+	switch code {
+	case http.StatusTooManyRequests:
+		return "rate limited"
+	case 529:
+		return "Claude is overloaded"
+	default:
+		return fmt.Sprintf("request failed with status %d", code)
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultContextWindow is assumed for models whose window is unknown.
+	DefaultContextWindow = 200000
+	// openAIContextWindow is assumed for OpenAI-compatible models, which
+	// range from small local models to large hosted ones.
+	openAIContextWindow = 128000
+)
+
+// TokenCounter is implemented by providers that can count the tokens of a
+// conversation exactly.
+type TokenCounter interface {
+	// CountTokens returns the number of input tokens messages would use.
+	CountTokens(ctx context.Context, messages []Message) (int, error)
+}
+
+// Usage is the number of tokens consumed by requests to a provider.
+type Usage struct {
+	InputTokens  int64
+	OutputTokens int64
+}
+
+// UsageReporter is implemented by providers that track token usage.
+type UsageReporter interface {
+	// Usage returns the tokens used by all requests made so far.
+	Usage() Usage
+}
+
+// usageMeter accumulates token usage across requests.
+type usageMeter struct {
+	mu    sync.Mutex
+	total Usage
+}
+
+func (m *usageMeter) add(input, output int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.total.InputTokens += input
+	m.total.OutputTokens += output
+}
+
+// Usage returns the tokens used by all requests made so far.
+func (m *usageMeter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.total
+}
+
+// ContextWindow returns the context window of the configured model in
+// tokens, preferring an explicitly configured size.
+func (c ProviderConfig) ContextWindow() int {
+	if c.ContextWindowTokens > 0 {
+		return c.ContextWindowTokens
+	}
+
+	if c.Name == ProviderOpenAI {
+		return openAIContextWindow
+	}
+
+	return DefaultContextWindow
+}
+
+// Tokenizer estimates token counts locally. The estimate follows how BPE
+// tokenizers split text - short words, runs of symbols, CJK characters - and
+// can be calibrated against an exact count from the API.
+type Tokenizer struct {
+	scale float64
+}
+
+// NewTokenizer returns an uncalibrated tokenizer.
+func NewTokenizer() *Tokenizer {
+	return &Tokenizer{scale: 1}
+}
+
+// Count estimates the number of tokens in text.
+func (t *Tokenizer) Count(text string) int {
+	return int(float64(estimateTokens(text))*t.scale + 0.5)
+}
+
+// Calibrate counts text exactly when provider implements TokenCounter and
+// scales later estimates by how far the local estimate was off. It returns
+// the exact count, or the estimate when no exact count is available.
+func (t *Tokenizer) Calibrate(ctx context.Context, provider Provider, text string) int {
+	estimate := estimateTokens(text)
+
+	counter, ok := provider.(TokenCounter)
+	if !ok || estimate == 0 {
+		return t.Count(text)
+	}
+
+	exact, err := counter.CountTokens(ctx, []Message{{Text: text}})
+	if err != nil || exact <= 0 {
+		return t.Count(text)
+	}
+
+	// Guard against a count dominated by fixed overhead such as the system prompt
+	t.scale = min(max(float64(exact)/float64(estimate), 0.25), 4)
+
+	return exact
+}
+
+// estimateTokens approximates the token count of text without a vocabulary.
+func estimateTokens(text string) int {
+	tokens := 0
+	word := 0
+	spaces := 0
+
+	flushWord := func() {
+		// Common words are one token; longer identifiers split every ~4 chars
+		tokens += (word + 3) / 4
+		word = 0
+	}
+
+	flushSpaces := func() {
+		// A single space merges into the next word; indentation costs more
+		if spaces > 1 {
+			tokens += 1 + spaces/8
+		}
+
+		spaces = 0
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		switch {
+		case r < utf8.RuneSelf && (r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)):
+			flushSpaces()
+
+			word++
+		case r == ' ' || r == '\t':
+			flushWord()
+
+			spaces++
+		case r == '\n' || r == '\r':
+			flushWord()
+			flushSpaces()
+
+			tokens++
+		case isIdeograph(r):
+			flushWord()
+			flushSpaces()
+
+			tokens++
+		case r >= utf8.RuneSelf && unicode.IsLetter(r):
+			// Accented and non-Latin letters cost about as much per byte as ASCII
+			flushSpaces()
+
+			word += size
+		default:
+			flushWord()
+			flushSpaces()
+
+			// Symbols, punctuation and emoji rarely merge with neighbours
+			tokens += (size + 1) / 2
+		}
+	}
+
+	flushWord()
+	flushSpaces()
+
+	return tokens
+}
+
+// isIdeograph reports whether r is a CJK, kana or Hangul character, which
+// tokenizers encode at roughly one token each.
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -1,6 +1,8 @@
 package commit
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
@@ -8,16 +10,20 @@ import (
 
 	"gic/internal/client"
 	"gic/internal/git"
+	"gic/internal/semantic"
 )
 
 var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
 
 const (
-	// Conservative limit: ~125K tokens (500K chars ≈ 125K tokens at 4 chars/token)
-	// Leaves room for system prompt + response
-	MaxPromptChars = 500000
-	// Reserve space for prompt template overhead (~2K chars)
-	PromptOverhead = 2000
+	// PromptOverheadTokens reserves room for the prompt template, style
+	// rules and project instructions around the git output.
+	PromptOverheadTokens = 1500
+	// ResponseReserveTokens keeps part of the context window free for the
+	// response and any validation re-asks.
+	ResponseReserveTokens = 8000
+	// charsPerToken converts the legacy character budget into tokens.
+	charsPerToken = 4
 )
 
 // CleanStatus strips ANSI codes and trailing whitespace from each line.
@@ -40,7 +46,10 @@ func CleanStatus(s string) string {
 }
 
 // BuildSmartDiff creates an intelligent diff when the full diff is too large.
-func BuildSmartDiff(fileStats []git.FileChange, fullDiff string, budget int) string {
+// Files whose symbols show public API changes come first, then the smallest
+// files, until their diffs would exceed budget tokens as measured by tok;
+// the rest are only listed in the summary.
+func BuildSmartDiff(fileStats []git.FileChange, symbols []semantic.File, fullDiff string, budget int, tok *client.Tokenizer) string {
 	if len(fileStats) == 0 {
 		return fullDiff
 	}
@@ -48,66 +57,435 @@ func BuildSmartDiff(fileStats []git.FileChange, fullDiff string, budget int) str
 	var result strings.Builder
 
 	// Write summary header with all files
-	result.WriteString("Changed Files Summary:\n")
+	result.WriteString(changeSummary(fileStats))
+
+	selected, excluded := selectDiffs(measureDiffs(fullDiff, symbols, tok), budget-tok.Count(result.String()))
+
+	writeDiffs(&result, selected)
+
+	// Note excluded files
+	if len(excluded) > 0 {
+		excludedPaths := make([]string, len(excluded))
+		for i, file := range excluded {
+			excludedPaths[i] = file.path
+		}
+
+		result.WriteString(fmt.Sprintf("\n[Note: Diffs excluded for %d large files: %s]\n",
+			len(excludedPaths), strings.Join(excludedPaths, ", ")))
+	}
+
+	return result.String()
+}
+
+// fileDiff is the diff of a single file and its size in tokens.
+type fileDiff struct {
+	path   string
+	diff   string
+	tokens int
+	// api is set when the file changes a public API.
+	api bool
+}
+
+// changeSummary lists every changed file with its line counts.
+func changeSummary(fileStats []git.FileChange) string {
+	var b strings.Builder
+
+	b.WriteString("Changed Files Summary:\n")
 
 	for _, stat := range fileStats {
-		result.WriteString(fmt.Sprintf("  %s: +%d -%d lines\n", stat.Path, stat.Added, stat.Removed))
+		b.WriteString(fmt.Sprintf("  %s: %s\n", stat.Path, stat.Describe()))
 	}
 
-	result.WriteString("\n")
+	b.WriteString("\n")
 
-	summarySize := result.Len()
+	return b.String()
+}
 
-	// Sort files by total changes (smallest first - more signal, less noise)
-	sorted := make([]git.FileChange, len(fileStats))
-	copy(sorted, fileStats)
+// measureDiffs splits fullDiff per file and measures each diff as it will
+// appear in the prompt.
+func measureDiffs(fullDiff string, symbols []semantic.File, tok *client.Tokenizer) []fileDiff {
+	api := apiPaths(symbols)
+
+	var files []fileDiff
+
+	for path, diff := range git.SplitDiff(fullDiff) {
+		files = append(files, fileDiff{path: path, diff: diff, tokens: tok.Count(diff), api: api[path]})
+	}
+
+	return files
+}
+
+// selectDiffs picks files that change public APIs first, then the smallest
+// - more signal, less noise - while they fit within budget tokens. Both
+// results are in path order so related files stay together.
+func selectDiffs(files []fileDiff, budget int) (selected, excluded []fileDiff) {
+	sorted := make([]fileDiff, len(files))
+	copy(sorted, files)
 	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Added+sorted[i].Removed < sorted[j].Added+sorted[j].Removed
-	})
+		if sorted[i].api != sorted[j].api {
+			return sorted[i].api
+		}
 
-	// Select files that fit within budget
-	var (
-		selectedPaths []string
-		excludedPaths []string
-	)
+		if sorted[i].tokens != sorted[j].tokens {
+			return sorted[i].tokens < sorted[j].tokens
+		}
 
-	usedBudget := summarySize
+		return sorted[i].path < sorted[j].path
+	})
 
-	for _, stat := range sorted {
-		// Estimate size per file (rough: ~5 chars per line change for context)
-		estimatedSize := (stat.Added + stat.Removed) * 5
+	used := 0
 
-		if usedBudget+estimatedSize > budget {
-			excludedPaths = append(excludedPaths, stat.Path)
+	for _, file := range sorted {
+		if used+file.tokens > budget {
+			excluded = append(excluded, file)
 
 			continue
 		}
 
-		selectedPaths = append(selectedPaths, stat.Path)
-		usedBudget += estimatedSize
+		selected = append(selected, file)
+		used += file.tokens
+	}
+
+	byPath := func(files []fileDiff) {
+		sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	}
+
+	byPath(selected)
+	byPath(excluded)
+
+	return selected, excluded
+}
+
+// writeDiffs appends the full diffs of the selected files.
+func writeDiffs(b *strings.Builder, selected []fileDiff) {
+	if len(selected) == 0 {
+		return
+	}
+
+	b.WriteString("Detailed Diffs (selected files):\n\n")
+
+	for _, file := range selected {
+		b.WriteString(file.diff)
+	}
+}
+
+// FitDiff prepares the diff for the prompt. It lists the changed symbols
+// ahead of the diff, which is kept unchanged when the prompt fits the token
+// budget of opts and reduced to a smart diff of the most relevant files
+// otherwise. The local token estimate is calibrated against the provider's
+// exact count when the prompt is close to the budget. With opts.Summarize,
+// files that do not fit are summarised by the model instead of being left
+// out. The second result reports whether the diff was trimmed.
+func FitDiff(ctx context.Context, provider client.Provider, status, diff, log string, fileStats []git.FileChange, opts Options) (string, bool, error) {
+	budget := opts.PromptBudget()
+	tok := client.NewTokenizer()
+
+	symbols := ChangedSymbols(ctx, diff)
+
+	symbolList := ""
+	if len(symbols) > 0 {
+		symbolList = semantic.Render(symbols, maxSymbolLines) + "\n"
+		budget -= tok.Count(symbolList)
+	}
+
+	total := tok.Count(status) + tok.Count(diff) + tok.Count(log) + PromptOverheadTokens
+
+	// Only pay for an exact count when the estimate could be wrong either way
+	if total > budget/2 {
+		total = tok.Calibrate(ctx, provider, status+"\n"+diff+"\n"+log) + PromptOverheadTokens
+	}
+
+	if total <= budget {
+		return symbolList + diff, false, nil
+	}
+
+	diffBudget := budget - tok.Count(status) - tok.Count(log) - PromptOverheadTokens
+
+	if opts.Summarize {
+		smartDiff, err := SummarizeDiff(ctx, provider, fileStats, symbols, diff, diffBudget, tok)
+
+		return symbolList + smartDiff, true, err
+	}
+
+	return symbolList + BuildSmartDiff(fileStats, symbols, diff, diffBudget, tok), true, nil
+}
+
+// Revision is a previous draft together with the user's feedback on it.
+type Revision struct {
+	Draft    string
+	Feedback string
+}
+
+// MaxCandidates caps how many alternative messages GenerateCandidates asks for.
+const MaxCandidates = 5
+
+// Options tunes how messages are generated.
+type Options struct {
+	// Style is the format messages must follow.
+	Style Style
+	// Language is the natural language messages are written in; empty means
+	// whatever fits the recent commits.
+	Language string
+	// Instructions are extra project-specific rules appended to the prompt.
+	Instructions string
+	// ContextWindow is the model's context window in tokens; zero means
+	// client.DefaultContextWindow.
+	ContextWindow int
+	// MaxPromptTokens replaces the budget derived from ContextWindow when
+	// positive.
+	MaxPromptTokens int
+	// MaxPromptChars is the legacy character budget, used when
+	// MaxPromptTokens is unset.
+	MaxPromptChars int
+	// Summarize has the model summarise files that do not fit the prompt
+	// budget instead of leaving them out.
+	Summarize bool
+}
+
+// PromptBudget returns the prompt size in tokens above which the diff is
+// trimmed.
+func (o Options) PromptBudget() int {
+	switch {
+	case o.MaxPromptTokens > 0:
+		return o.MaxPromptTokens
+	case o.MaxPromptChars > 0:
+		return o.MaxPromptChars / charsPerToken
+	}
+
+	window := o.ContextWindow
+	if window <= 0 {
+		window = client.DefaultContextWindow
+	}
+
+	return max(window-ResponseReserveTokens, window/2)
+}
+
+// rules renders the style rules followed by the configured language and
+// project instructions.
+func (o Options) rules(fileStats []git.FileChange) string {
+	rules := styleRules(o.Style, fileStats)
+
+	if o.Language != "" {
+		rules += fmt.Sprintf("\n\nWrite the message in %s, keeping format keywords such as commit types in English.", o.Language)
+	}
+
+	if instructions := strings.TrimSpace(o.Instructions); instructions != "" {
+		rules += "\n\nProject-specific instructions:\n" + instructions
+	}
+
+	return rules
+}
+
+// maxValidationAttempts bounds how often Claude is re-asked for a message
+// that fails style validation.
+const maxValidationAttempts = 3
+
+// GenerateMessage uses Claude to generate a commit message. Revisions, if any,
+// are replayed as conversation history so Claude refines its earlier drafts.
+func GenerateMessage(ctx context.Context, provider client.Provider, status, diff, log string, fileStats []git.FileChange, userInput string, opts Options, revisions ...Revision) (string, error) {
+	return generateMessage(ctx, provider.Ask, status, diff, log, fileStats, userInput, opts, revisions...)
+}
+
+// StreamMessage is GenerateMessage with the response streamed to onText as it
+// is generated. onText receives the text of the current attempt so far, so a
+// re-ask after failed validation starts over from an empty message.
+func StreamMessage(ctx context.Context, provider client.Provider, onText func(string), status, diff, log string, fileStats []git.FileChange, userInput string, opts Options, revisions ...Revision) (string, error) {
+	ask := func(ctx context.Context, messages []client.Message) (string, error) {
+		return client.AskStream(ctx, provider, messages, onText)
+	}
+
+	return generateMessage(ctx, ask, status, diff, log, fileStats, userInput, opts, revisions...)
+}
+
+// MessagePrompt returns the prompt GenerateMessage sends, for hosts that run
+// it with a model of their own.
+func MessagePrompt(status, diff, log string, fileStats []git.FileChange, userInput string, opts Options) string {
+	return ContextPrompt("generate a concise commit message", status, diff, log, fileStats, userInput) + `
+
+IMPORTANT: Your entire response must be ONLY the commit message text itself.
+Do NOT include:
+- Any analysis or explanation
+- Prefixes like "Claude:", "Here's", "Based on"
+- Phrases like "I'll analyze" or "my suggested commit message is"
+- Signatures or attributions
+
+` + opts.rules(fileStats) + `
+
+Start your response directly with the commit message text.`
+}
+
+// generateMessage builds the commit message conversation and asks it through
+// ask until the response follows the requested style.
+func generateMessage(ctx context.Context, ask askFunc, status, diff, log string, fileStats []git.FileChange, userInput string, opts Options, revisions ...Revision) (string, error) {
+	messages := []client.Message{{Text: MessagePrompt(status, diff, log, fileStats, userInput, opts)}}
+
+	for _, r := range revisions {
+		messages = append(messages,
+			client.Message{Assistant: true, Text: r.Draft},
+			client.Message{Text: fmt.Sprintf(`Revise the commit message based on this feedback:
+`+"```"+`
+%s
+`+"```"+`
+
+Respond with ONLY the revised commit message text.`, r.Feedback)},
+		)
+	}
+
+	return askValidated(ctx, ask, messages, func(response string) error {
+		return opts.Style.Validate(strings.TrimSpace(response))
+	})
+}
+
+// askFunc sends a conversation and returns the response text.
+type askFunc func(ctx context.Context, messages []client.Message) (string, error)
+
+// askValidated asks Claude and, while validate rejects the response, re-asks
+// with the validation error as feedback.
+func askValidated(ctx context.Context, ask askFunc, messages []client.Message, validate func(string) error) (string, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < maxValidationAttempts; attempt++ {
+		response, err := ask(ctx, messages)
+		if err != nil {
+			return "", err
+		}
+
+		if lastErr = validate(response); lastErr == nil {
+			return response, nil
+		}
+
+		messages = append(messages,
+			client.Message{Assistant: true, Text: response},
+			client.Message{Text: fmt.Sprintf("That response is invalid: %v\n\nFix it and respond again in the required format only.", lastErr)},
+		)
+	}
+
+	return "", fmt.Errorf("no valid response after %d attempts: %w", maxValidationAttempts, lastErr)
+}
+
+// styleRules renders the message format instructions for a style.
+func styleRules(style Style, fileStats []git.FileChange) string {
+	if style != StyleConventional {
+		return `Write a commit message that:
+1. Summarizes the changes concisely (1-2 sentences)
+2. Focuses on WHY rather than WHAT
+3. Follows the style of recent commits shown above`
+	}
+
+	scopeHint := "no common scope was detected; omit the scope unless one is obvious"
+	if scope := InferScope(fileStats); scope != "" {
+		scopeHint = fmt.Sprintf("the changed paths suggest the scope %q", scope)
+	}
+
+	return fmt.Sprintf(`Write a commit message in Conventional Commits format:
+
+type(scope)!: description
+
+optional body
+
+optional footers
+
+Rules:
+1. type is one of: %s
+2. scope is optional; %s
+3. Add "!" after the type/scope and a "BREAKING CHANGE: <what breaks>" footer only when the change breaks compatibility
+4. description is imperative, lowercase, without a trailing period; the header stays under %d characters
+5. Separate header, body and footers with blank lines
+6. The body, when present, focuses on WHY rather than WHAT`, strings.Join(ConventionalTypes, ", "), scopeHint, MaxConventionalHeader)
+}
+
+// candidatesTool is the structured answer used by GenerateCandidates.
+var candidatesTool = client.Tool{
+	Name:        "submit_commit_messages",
+	Description: "Submit the candidate commit messages.",
+	Properties: map[string]any{
+		"messages": map[string]any{
+			"type":        "array",
+			"description": "Distinct candidate commit messages, each containing only the message text",
+			"items":       map[string]any{"type": "string"},
+		},
+	},
+	Required: []string{"messages"},
+}
+
+// GenerateCandidates asks Claude for count distinct commit messages in a
+// single structured response.
+func GenerateCandidates(ctx context.Context, provider client.Provider, status, diff, log string, fileStats []git.FileChange, userInput string, opts Options, count int) ([]string, error) {
+	if count < 1 || count > MaxCandidates {
+		return nil, fmt.Errorf("candidate count must be between 1 and %d", MaxCandidates)
+	}
+
+	prompt := ContextPrompt(fmt.Sprintf("propose %d alternative commit messages", count), status, diff, log, fileStats, userInput) + fmt.Sprintf(`
+
+Submit exactly %d candidate commit messages using the submit_commit_messages tool.
+
+Each candidate must contain ONLY the commit message text, without analysis, prefixes or attributions.
+
+%s
+
+Make the candidates genuinely different: vary length (a terse one-line summary vs a subject with an explanatory body) and emphasis (which aspect of the change leads).`, count, opts.rules(fileStats))
+
+	raw, err := provider.AskStructured(ctx, []client.Message{{Text: prompt}}, candidatesTool)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := ParseCandidates(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop candidates that do not follow the requested style
+	var valid []string
+
+	for _, msg := range candidates {
+		if opts.Style.Validate(msg) == nil {
+			valid = append(valid, msg)
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("none of the %d candidates follow the %s style", len(candidates), opts.Style)
+	}
+
+	return valid, nil
+}
+
+// ParseCandidates extracts distinct, non-empty messages from a
+// submit_commit_messages answer.
+func ParseCandidates(raw []byte) ([]string, error) {
+	var answer struct {
+		Messages []string `json:"messages"`
+	}
+
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse candidates: %w", err)
 	}
 
-	// Get diff for selected files only
-	if len(selectedPaths) > 0 {
-		result.WriteString("Detailed Diffs (selected files):\n\n")
+	seen := make(map[string]bool)
+
+	var candidates []string
 
-		selectedDiff, err := git.DiffFiles(selectedPaths)
-		if err == nil {
-			result.WriteString(selectedDiff)
+	for _, msg := range answer.Messages {
+		msg = strings.TrimSpace(msg)
+		if msg == "" || seen[msg] {
+			continue
 		}
+
+		seen[msg] = true
+		candidates = append(candidates, msg)
 	}
 
-	// Note excluded files
-	if len(excludedPaths) > 0 {
-		result.WriteString(fmt.Sprintf("\n[Note: Diffs excluded for %d large files: %s]\n",
-			len(excludedPaths), strings.Join(excludedPaths, ", ")))
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no candidate messages returned")
 	}
 
-	return result.String()
+	return candidates, nil
 }
 
-// GenerateMessage uses Claude to generate a commit message.
-func GenerateMessage(accessToken, status, diff, log string, fileStats []git.FileChange, userInput string) (string, error) {
+// ContextPrompt renders the repository context shared by all generation
+// prompts, asking the model to perform task.
+func ContextPrompt(task, status, diff, log string, fileStats []git.FileChange, userInput string) string {
 	// Check if we have file stats and diff looks like our smart diff
 	hasSmartDiff := len(fileStats) > 0 && strings.Contains(diff, "Changed Files Summary:")
 
@@ -116,6 +494,22 @@ func GenerateMessage(accessToken, status, diff, log string, fileStats []git.File
 		contextNote = "\n(Note: Due to large changeset, detailed diffs shown for selected files only. Use summary above for full picture.)\n"
 	}
 
+	// Renames, binary files and mode changes are invisible in line counts
+	fileChangesSection := ""
+	if !hasSmartDiff {
+		var notable strings.Builder
+
+		for _, stat := range fileStats {
+			if stat.Notable() {
+				notable.WriteString(fmt.Sprintf("%s: %s\n", stat.Path, stat.Describe()))
+			}
+		}
+
+		if notable.Len() > 0 {
+			fileChangesSection = "\n\nFile Changes:\n```\n" + notable.String() + "```"
+		}
+	}
+
 	userInputSection := ""
 	if userInput != "" {
 		userInputSection = fmt.Sprintf(`
@@ -127,12 +521,12 @@ User Input:
 `, userInput)
 	}
 
-	prompt := fmt.Sprintf(`Analyze the following git repository state and generate a concise commit message.
+	return fmt.Sprintf(`Analyze the following git repository state and %s.
 
 Git Status:
 `+"```"+`
 %s
-`+"```"+`
+`+"```"+`%s
 
 Git Diff:
 `+"```"+`
@@ -142,21 +536,5 @@ Git Diff:
 Recent Commits (for style reference):
 `+"```"+`
 %s
-`+"```"+`%s
-
-IMPORTANT: Your entire response must be ONLY the commit message text itself.
-Do NOT include:
-- Any analysis or explanation
-- Prefixes like "Claude:", "Here's", "Based on"
-- Phrases like "I'll analyze" or "my suggested commit message is"
-- Signatures or attributions
-
-Write a commit message that:
-1. Summarizes the changes concisely (1-2 sentences)
-2. Focuses on WHY rather than WHAT
-3. Follows the style of recent commits shown above
-
-Start your response directly with the commit message text.`, status, diff, contextNote, log, userInputSection)
-
-	return client.Ask(accessToken, prompt)
+`+"```"+`%s`, task, status, fileChangesSection, diff, contextNote, log, userInputSection)
 }
@@ -0,0 +1,183 @@
+package commit
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gic/internal/git"
+)
+
+// Style selects the format generated commit messages must follow.
+type Style string
+
+const (
+	// StyleDefault follows the style of recent commits.
+	StyleDefault Style = "default"
+	// StyleConventional enforces the Conventional Commits specification.
+	StyleConventional Style = "conventional"
+)
+
+// ParseStyle validates a style name. An empty name means StyleDefault.
+func ParseStyle(name string) (Style, error) {
+	switch Style(name) {
+	case "", StyleDefault:
+		return StyleDefault, nil
+	case StyleConventional:
+		return StyleConventional, nil
+	default:
+		return "", fmt.Errorf("unknown commit style %q (expected default or conventional)", name)
+	}
+}
+
+// Validate checks that a message conforms to the style.
+func (s Style) Validate(message string) error {
+	if s != StyleConventional {
+		return nil
+	}
+
+	_, err := ParseConventional(message)
+
+	return err
+}
+
+// ConventionalTypes lists the commit types accepted in conventional style.
+var ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// MaxConventionalHeader is the longest header accepted in conventional style.
+const MaxConventionalHeader = 100
+
+var (
+	conventionalHeaderRegex = regexp.MustCompile(`^([a-z]+)(?:\(([^()\s]+)\))?(!)?: (\S.*)$`)
+	footerRegex             = regexp.MustCompile(`^(BREAKING CHANGE|BREAKING-CHANGE|[A-Za-z][A-Za-z-]*)(?:: | #)(.+)$`)
+)
+
+// Footer is a "Token: value" trailer of a conventional commit.
+type Footer struct {
+	Token string
+	Value string
+}
+
+// ConventionalCommit is a parsed Conventional Commits message.
+type ConventionalCommit struct {
+	Type        string
+	Scope       string
+	Breaking    bool
+	Description string
+	Body        string
+	Footers     []Footer
+}
+
+// ParseConventional parses and validates a Conventional Commits message.
+func ParseConventional(message string) (*ConventionalCommit, error) {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n")
+
+	header := lines[0]
+	if len(header) > MaxConventionalHeader {
+		return nil, fmt.Errorf("header is %d characters long, the limit is %d", len(header), MaxConventionalHeader)
+	}
+
+	m := conventionalHeaderRegex.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("header %q does not match \"type(scope)!: description\"", header)
+	}
+
+	c := &ConventionalCommit{
+		Type:        m[1],
+		Scope:       m[2],
+		Breaking:    m[3] == "!",
+		Description: strings.TrimSpace(m[4]),
+	}
+
+	if !isConventionalType(c.Type) {
+		return nil, fmt.Errorf("unknown type %q (expected one of: %s)", c.Type, strings.Join(ConventionalTypes, ", "))
+	}
+
+	if len(lines) == 1 {
+		return c, nil
+	}
+
+	if strings.TrimSpace(lines[1]) != "" {
+		return nil, fmt.Errorf("header must be followed by a blank line")
+	}
+
+	// Footers are the trailing paragraph when every line in it is a footer
+	rest := lines[2:]
+	footerStart := len(rest)
+
+	for i := len(rest) - 1; i >= 0; i-- {
+		if strings.TrimSpace(rest[i]) == "" {
+			break
+		}
+
+		if !footerRegex.MatchString(rest[i]) {
+			footerStart = len(rest)
+			break
+		}
+
+		footerStart = i
+	}
+
+	c.Body = strings.TrimSpace(strings.Join(rest[:footerStart], "\n"))
+
+	for _, line := range rest[footerStart:] {
+		fm := footerRegex.FindStringSubmatch(line)
+		token := fm[1]
+
+		if token == "BREAKING CHANGE" || token == "BREAKING-CHANGE" {
+			c.Breaking = true
+		}
+
+		c.Footers = append(c.Footers, Footer{Token: token, Value: strings.TrimSpace(fm[2])})
+	}
+
+	return c, nil
+}
+
+// InferScope suggests a scope from the changed paths: the last component of
+// their deepest common directory, skipping generic container directories.
+func InferScope(fileStats []git.FileChange) string {
+	var common []string
+
+	for i, stat := range fileStats {
+		dir := path.Dir(stat.Path)
+		if dir == "." {
+			return ""
+		}
+
+		parts := strings.Split(dir, "/")
+		if i == 0 {
+			common = parts
+			continue
+		}
+
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+
+		common = common[:n]
+	}
+
+	for i := len(common) - 1; i >= 0; i-- {
+		switch common[i] {
+		case "internal", "pkg", "cmd", "src", "lib":
+			continue
+		}
+
+		return common[i]
+	}
+
+	return ""
+}
+
+func isConventionalType(t string) bool {
+	for _, known := range ConventionalTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,382 @@
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gic/internal/client"
+	"gic/internal/git"
+)
+
+// PlannedCommit is one commit in a proposed split of a changeset.
+type PlannedCommit struct {
+	Message string
+	// Files are committed with all their changes.
+	Files []string
+	// Hunks holds, for each file whose changes are spread over several
+	// commits, the indexes of the hunks committed here, in diff order.
+	Hunks map[string][]int
+}
+
+// SplittableFiles returns the files of a staged diff whose hunks a plan may
+// spread over several commits: modified text files with more than one hunk.
+// The diff must not be redacted, since its hunks are applied to the index.
+func SplittableFiles(diff string, fileStats []git.FileChange) map[string]git.FilePatch {
+	plain := make(map[string]bool, len(fileStats))
+	for _, stat := range fileStats {
+		plain[stat.Path] = !stat.Notable()
+	}
+
+	files := make(map[string]git.FilePatch)
+
+	for _, fp := range git.ParsePatch(diff) {
+		if plain[fp.Path] && len(fp.Hunks) > 1 {
+			files[fp.Path] = fp
+		}
+	}
+
+	return files
+}
+
+// planTool is the structured answer used by PlanCommits.
+var planTool = client.Tool{
+	Name:        "submit_commit_plan",
+	Description: "Submit the planned commits in the order they should be created.",
+	Properties: map[string]any{
+		"commits": map[string]any{
+			"type":        "array",
+			"description": "The commits to create, in order",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"message": map[string]any{
+						"type":        "string",
+						"description": "The commit message text only",
+					},
+					"files": map[string]any{
+						"type":        "array",
+						"description": "Paths of the changed files in this commit with all their changes, exactly as listed",
+						"items":       map[string]any{"type": "string"},
+					},
+					"hunks": map[string]any{
+						"type":        "array",
+						"description": `Hunks of split files in this commit, as "<path>#<number>" from the list of hunks`,
+						"items":       map[string]any{"type": "string"},
+					},
+				},
+				"required": []string{"message", "files"},
+			},
+		},
+	},
+	Required: []string{"commits"},
+}
+
+// PlanCommits asks Claude to group the staged changes into several coherent
+// commits, each with its own message. The hunks of splittable files, as
+// returned by SplittableFiles, may go into different commits.
+func PlanCommits(ctx context.Context, provider client.Provider, status, diff, log string, fileStats []git.FileChange, splittable map[string]git.FilePatch, userInput string, opts Options) ([]PlannedCommit, error) {
+	var files, hunks strings.Builder
+
+	for _, stat := range fileStats {
+		files.WriteString(fmt.Sprintf("%s (%s)\n", stat.Path, stat.Describe()))
+
+		for i, h := range splittable[stat.Path].Hunks {
+			fmt.Fprintf(&hunks, "%s#%d: lines %d-%d (+%d -%d)\n", stat.Path, i+1, h.NewStart, h.NewStart+max(h.NewLines, 1)-1, h.Added(), h.Removed())
+		}
+	}
+
+	// Only line numbers are listed; the diff shows what each hunk changes
+	hunksSection, hunkRule := "", ""
+	if hunks.Len() > 0 {
+		hunksSection = "\n\nHunks of Files That May Be Split:\n```\n" + hunks.String() + "```"
+		hunkRule = "\n5. A file listed under hunks may instead be split when its hunks belong to unrelated changes: put each hunk, as \"<path>#<number>\", in the hunks of the commit it belongs to"
+	}
+
+	userInputSection := ""
+	if userInput != "" {
+		userInputSection = fmt.Sprintf(`
+
+User Input:
+`+"```"+`
+%s
+`+"```"+`
+`, userInput)
+	}
+
+	prompt := fmt.Sprintf(`Analyze the following staged changes and split them into a small number of coherent, logically independent commits.
+
+Changed Files:
+`+"```"+`
+%s`+"```"+`%s
+
+Git Status:
+`+"```"+`
+%s
+`+"```"+`
+
+Git Diff:
+`+"```"+`
+%s
+`+"```"+`
+
+Recent Commits (for style reference):
+`+"```"+`
+%s
+`+"```"+`%s
+
+Submit the plan using the submit_commit_plan tool.
+
+Rules:
+1. Every changed file listed above must appear in exactly one commit
+2. Use the file paths exactly as listed above
+3. Order commits so that each one builds on the previous ones
+4. Prefer fewer, meaningful commits over many tiny ones%s
+
+For each commit message:
+%s`, files.String(), hunksSection, status, diff, log, userInputSection, hunkRule, opts.rules(fileStats))
+
+	ask := func(ctx context.Context, messages []client.Message) (string, error) {
+		raw, err := provider.AskStructured(ctx, messages, planTool)
+		return string(raw), err
+	}
+
+	response, err := askValidated(ctx, ask, []client.Message{{Text: prompt}}, func(response string) error {
+		plan, err := ParsePlan([]byte(response), fileStats, splittable)
+		if err != nil {
+			return err
+		}
+
+		for i, c := range plan {
+			if err := opts.Style.Validate(c.Message); err != nil {
+				return fmt.Errorf("message of commit %d: %w", i+1, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePlan([]byte(response), fileStats, splittable)
+}
+
+// ParsePlan parses a submit_commit_plan answer and reconciles it with the
+// actual changed files: unknown paths and hunks are dropped, duplicates keep
+// their first assignment and changes the plan forgot are added to the last
+// commit. A file named whole after some of its hunks were assigned takes the
+// rest of them, and a file whose hunks all end up in one commit is committed
+// whole.
+func ParsePlan(raw []byte, fileStats []git.FileChange, splittable map[string]git.FilePatch) ([]PlannedCommit, error) {
+	var answer struct {
+		Commits []struct {
+			Message string   `json:"message"`
+			Files   []string `json:"files"`
+			Hunks   []string `json:"hunks"`
+		} `json:"commits"`
+	}
+
+	if err := json.Unmarshal(raw, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse commit plan: %w", err)
+	}
+
+	known := make(map[string]bool, len(fileStats))
+	for _, stat := range fileStats {
+		known[stat.Path] = true
+	}
+
+	whole := make(map[string]bool, len(fileStats))
+	taken := make(map[string]map[int]bool)
+
+	// takeRest assigns the hunks of path that are not assigned yet to c
+	takeRest := func(c *PlannedCommit, path string) {
+		for i := range splittable[path].Hunks {
+			if !taken[path][i] {
+				taken[path][i] = true
+				c.Hunks[path] = append(c.Hunks[path], i)
+			}
+		}
+	}
+
+	var plan []PlannedCommit
+
+	for _, c := range answer.Commits {
+		planned := PlannedCommit{Message: strings.TrimSpace(c.Message), Hunks: make(map[string][]int)}
+
+		for _, ref := range c.Hunks {
+			path, number, _ := strings.Cut(ref, "#")
+			n, err := strconv.Atoi(number)
+
+			if err != nil || whole[path] || n < 1 || n > len(splittable[path].Hunks) || taken[path][n-1] {
+				continue
+			}
+
+			if taken[path] == nil {
+				taken[path] = make(map[int]bool)
+			}
+
+			taken[path][n-1] = true
+			planned.Hunks[path] = append(planned.Hunks[path], n-1)
+		}
+
+		for _, path := range c.Files {
+			switch {
+			case !known[path] || whole[path]:
+			case taken[path] != nil:
+				takeRest(&planned, path)
+			default:
+				whole[path] = true
+				planned.Files = append(planned.Files, path)
+			}
+		}
+
+		if len(planned.Files) == 0 && len(planned.Hunks) == 0 {
+			continue
+		}
+
+		if planned.Message == "" {
+			return nil, fmt.Errorf("commit plan contains a commit without a message")
+		}
+
+		plan = append(plan, planned)
+	}
+
+	if len(plan) == 0 {
+		return nil, fmt.Errorf("commit plan does not cover any changed files")
+	}
+
+	last := &plan[len(plan)-1]
+
+	for _, stat := range fileStats {
+		switch {
+		case whole[stat.Path]:
+		case taken[stat.Path] != nil:
+			takeRest(last, stat.Path)
+		default:
+			last.Files = append(last.Files, stat.Path)
+		}
+	}
+
+	// A file that ended up in a single commit needs no splitting
+	for path := range taken {
+		var owners []int
+
+		for i := range plan {
+			if len(plan[i].Hunks[path]) > 0 {
+				owners = append(owners, i)
+			}
+		}
+
+		if len(owners) == 1 {
+			delete(plan[owners[0]].Hunks, path)
+			plan[owners[0]].Files = append(plan[owners[0]].Files, path)
+		}
+	}
+
+	for i := range plan {
+		for _, hunks := range plan[i].Hunks {
+			slices.Sort(hunks)
+		}
+	}
+
+	return plan, nil
+}
+
+// restoreTimeout bounds restoring the index after a failed CommitPlan, which
+// must not depend on the request that may have been cancelled.
+const restoreTimeout = 30 * time.Second
+
+// CommitPlan creates one commit per planned group, calling progress with the
+// number of commits created so far after each one. Whole files are staged
+// from a snapshot of the index and the hunks of split files from their
+// patches in splittable. The snapshot is restored if any commit fails, so no
+// staged change is lost. A renamed file's old path is committed together
+// with its new one.
+func CommitPlan(ctx context.Context, plan []PlannedCommit, fileStats []git.FileChange, splittable map[string]git.FilePatch, progress func(done int)) error {
+	oldPaths := make(map[string]string)
+
+	for _, stat := range fileStats {
+		if stat.Status == git.StatusRenamed {
+			oldPaths[stat.Path] = stat.OldPath
+		}
+	}
+
+	snapshot, err := git.WriteTree(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot index: %w", err)
+	}
+
+	if err := git.ReadTree(ctx, "HEAD"); err != nil {
+		// No commits yet: start from an empty index
+		if err := git.ReadTree(ctx, "--empty"); err != nil {
+			return restoreIndex(ctx, snapshot, fmt.Errorf("failed to reset index: %w", err))
+		}
+	}
+
+	base, err := git.WriteTree(ctx)
+	if err != nil {
+		return restoreIndex(ctx, snapshot, fmt.Errorf("failed to record the last commit's tree: %w", err))
+	}
+
+	// applied holds the hunks of each split file committed so far
+	applied := make(map[string][]int)
+
+	for i, c := range plan {
+		paths := append([]string(nil), c.Files...)
+
+		for _, path := range c.Files {
+			if old, ok := oldPaths[path]; ok {
+				paths = append(paths, old)
+			}
+		}
+
+		if err := git.StageFromTree(ctx, snapshot, paths); err != nil {
+			return restoreIndex(ctx, snapshot, fmt.Errorf("failed to stage files for commit %d: %w", i+1, err))
+		}
+
+		for _, path := range slices.Sorted(maps.Keys(c.Hunks)) {
+			applied[path] = append(applied[path], c.Hunks[path]...)
+			slices.Sort(applied[path])
+
+			// The patch's line numbers refer to the last commit, so its hunks
+			// are applied there together with those of earlier commits
+			if err := git.StageFromTree(ctx, base, []string{path}); err != nil {
+				return restoreIndex(ctx, snapshot, fmt.Errorf("failed to stage %s for commit %d: %w", path, i+1, err))
+			}
+
+			if err := git.ApplyCached(ctx, splittable[path].Select(applied[path])); err != nil {
+				return restoreIndex(ctx, snapshot, fmt.Errorf("failed to stage hunks of %s for commit %d: %w", path, i+1, err))
+			}
+		}
+
+		if err := git.Commit(ctx, c.Message); err != nil {
+			return restoreIndex(ctx, snapshot, fmt.Errorf("failed to create commit %d: %w", i+1, err))
+		}
+
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+
+	return nil
+}
+
+// restoreIndex puts the snapshot of the staged changes back after err. It
+// runs even when ctx is done, e.g. after Ctrl-C, and names the snapshot when
+// that fails too so that the user can restore it by hand.
+func restoreIndex(ctx context.Context, snapshot string, err error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+	defer cancel()
+
+	if restoreErr := git.ReadTree(ctx, snapshot); restoreErr != nil {
+		return fmt.Errorf("%w; restoring the staged changes also failed (%v), run \"git read-tree %s\" to get them back", err, restoreErr, snapshot)
+	}
+
+	return err
+}
@@ -0,0 +1,308 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gic/internal/client"
+	"gic/internal/git"
+	"gic/internal/semantic"
+)
+
+const (
+	// SummaryBatchTokens caps the diff text sent in a single summarisation
+	// request. Smaller files are batched together; larger ones are split.
+	SummaryBatchTokens = 30000
+	// summaryConcurrency bounds the summarisation requests in flight.
+	summaryConcurrency = 4
+	// maxReduceRounds bounds how often summaries are condensed further.
+	maxReduceRounds = 3
+)
+
+type summaryProgressKey struct{}
+
+// WithSummaryProgress returns a context that makes SummarizeDiff call
+// progress before the first summarisation request and after each one
+// finishes, with the number of finished and scheduled requests. Calls are
+// never concurrent.
+func WithSummaryProgress(ctx context.Context, progress func(done, total int)) context.Context {
+	return context.WithValue(ctx, summaryProgressKey{}, progress)
+}
+
+// summaryTracker reports summarisation progress to the callback on ctx.
+type summaryTracker struct {
+	mu          sync.Mutex
+	done, total int
+	progress    func(done, total int)
+}
+
+func newSummaryTracker(ctx context.Context) *summaryTracker {
+	progress, _ := ctx.Value(summaryProgressKey{}).(func(done, total int))
+
+	return &summaryTracker{progress: progress}
+}
+
+// schedule adds n requests to the total.
+func (t *summaryTracker) schedule(n int) {
+	t.update(0, n)
+}
+
+// finish marks one request as done.
+func (t *summaryTracker) finish() {
+	t.update(1, 0)
+}
+
+func (t *summaryTracker) update(done, total int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done += done
+	t.total += total
+
+	if t.progress != nil {
+		t.progress(t.done, t.total)
+	}
+}
+
+// SummarizeDiff is BuildSmartDiff for changesets where every file matters.
+// Files that do not fit the budget are summarised by the model in parallel
+// batches (map), and the summaries take their place in the prompt. While
+// the summaries together still exceed the room left for them, they are
+// condensed again (reduce), so even very large refactors are described.
+func SummarizeDiff(ctx context.Context, provider client.Provider, fileStats []git.FileChange, symbols []semantic.File, fullDiff string, budget int, tok *client.Tokenizer) (string, error) {
+	if len(fileStats) == 0 {
+		return fullDiff, nil
+	}
+
+	var result strings.Builder
+
+	result.WriteString(changeSummary(fileStats))
+
+	// Keep a quarter of the budget for summaries of the files left out
+	diffBudget := budget - tok.Count(result.String()) - budget/4
+
+	selected, excluded := selectDiffs(measureDiffs(fullDiff, symbols, tok), diffBudget)
+
+	writeDiffs(&result, selected)
+
+	if len(excluded) == 0 {
+		return result.String(), nil
+	}
+
+	// Diff budget the selected files did not use goes to the summaries too
+	summaryBudget := budget - tok.Count(result.String())
+	if summaryBudget <= 0 {
+		return BuildSmartDiff(fileStats, symbols, fullDiff, budget, tok), nil
+	}
+
+	tracker := newSummaryTracker(ctx)
+
+	summaries, err := summarizeFiles(ctx, provider, excluded, tok, tracker)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize large files: %w", err)
+	}
+
+	summary, err := condense(ctx, provider, summaries, summaryBudget, tok, tracker)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize large files: %w", err)
+	}
+
+	result.WriteString("\nSummaries of files too large to include in full:\n\n")
+	result.WriteString(summary)
+	result.WriteString("\n")
+
+	return result.String(), nil
+}
+
+// summarizeFiles is the map step: it summarises the diffs of files in
+// batches of at most SummaryBatchTokens.
+func summarizeFiles(ctx context.Context, provider client.Provider, files []fileDiff, tok *client.Tokenizer, tracker *summaryTracker) ([]string, error) {
+	batches := batchDiffs(files, SummaryBatchTokens, tok)
+
+	prompts := make([]string, len(batches))
+	for i, batch := range batches {
+		prompts[i] = `Summarize this part of a large git diff for someone writing the commit message.
+For each file, give the path followed by one to three short bullet points on what changed and why it matters.
+Name added, removed or renamed functions, types and public APIs. Respond with ONLY the summary.
+
+` + "```diff\n" + batch + "```"
+	}
+
+	return askAll(ctx, provider, prompts, tracker)
+}
+
+// condense is the reduce step: while the summaries exceed budget tokens it
+// merges them batch by batch into shorter ones.
+func condense(ctx context.Context, provider client.Provider, summaries []string, budget int, tok *client.Tokenizer, tracker *summaryTracker) (string, error) {
+	text := strings.Join(summaries, "\n\n")
+
+	for round := 0; round < maxReduceRounds && tok.Count(text) > budget; round++ {
+		chunks := splitTokens(text, SummaryBatchTokens, tok)
+
+		// Each condensed chunk gets an equal share of the budget, in words
+		words := max(budget/len(chunks)*3/4, 50)
+
+		prompts := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			prompts[i] = fmt.Sprintf(`Condense these summaries of changed files into at most %d words.
+Keep file paths and the most important changes, especially to public APIs. Respond with ONLY the condensed summary.
+
+%s`, words, chunk)
+		}
+
+		condensed, err := askAll(ctx, provider, prompts, tracker)
+		if err != nil {
+			return "", err
+		}
+
+		text = strings.Join(condensed, "\n\n")
+	}
+
+	// The model may ignore the word limit; never overrun the budget
+	if tok.Count(text) > budget {
+		text = splitTokens(text, budget, tok)[0] + "\n[Note: summaries truncated]"
+	}
+
+	return text, nil
+}
+
+// askAll sends each prompt as its own conversation, at most
+// summaryConcurrency at a time, and returns the responses in order. The
+// first failure cancels the remaining requests.
+func askAll(ctx context.Context, provider client.Provider, prompts []string, tracker *summaryTracker) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tracker.schedule(len(prompts))
+
+	var (
+		responses = make([]string, len(prompts))
+		firstErr  error
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		slots     = make(chan struct{}, summaryConcurrency)
+	)
+
+	for i, prompt := range prompts {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+
+			response, err := provider.Ask(ctx, []client.Message{{Text: prompt}})
+			if err != nil {
+				mu.Lock()
+
+				if firstErr == nil {
+					firstErr = err
+				}
+
+				mu.Unlock()
+				cancel()
+
+				return
+			}
+
+			responses[i] = strings.TrimSpace(response)
+
+			tracker.finish()
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// batchDiffs groups file diffs into batches of at most limit tokens,
+// splitting diffs that are larger than a batch on their own.
+func batchDiffs(files []fileDiff, limit int, tok *client.Tokenizer) []string {
+	var (
+		batches []string
+		current strings.Builder
+		used    int
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			batches = append(batches, current.String())
+		}
+
+		current.Reset()
+
+		used = 0
+	}
+
+	for _, file := range files {
+		if file.tokens > limit {
+			flush()
+
+			parts := splitTokens(file.diff, limit, tok)
+			for i, part := range parts {
+				batches = append(batches, fmt.Sprintf("[%s, part %d of %d]\n%s", file.path, i+1, len(parts), part))
+			}
+
+			continue
+		}
+
+		if used+file.tokens > limit {
+			flush()
+		}
+
+		current.WriteString(file.diff)
+
+		used += file.tokens
+	}
+
+	flush()
+
+	return batches
+}
+
+// splitTokens splits text at line boundaries into chunks of at most limit
+// tokens. A single line longer than limit becomes a chunk of its own.
+func splitTokens(text string, limit int, tok *client.Tokenizer) []string {
+	var (
+		chunks  []string
+		current strings.Builder
+		used    int
+	)
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		tokens := tok.Count(line)
+
+		if used+tokens > limit && current.Len() > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+
+			used = 0
+		}
+
+		current.WriteString(line)
+
+		used += tokens
+	}
+
+	if current.Len() > 0 || len(chunks) == 0 {
+		chunks = append(chunks, current.String())
+	}
+
+	return chunks
+}
@@ -0,0 +1,85 @@
+package commit
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"gic/internal/git"
+	"gic/internal/semantic"
+)
+
+// maxSymbolLines caps how many changed symbols are listed in the prompt.
+const maxSymbolLines = 150
+
+// ChangedSymbols compares the committed and staged versions of each file in
+// diff that a semantic parser understands. Files that cannot be read or
+// parsed are left out; the diff still covers them.
+func ChangedSymbols(ctx context.Context, diff string) []semantic.File {
+	var paths []string
+
+	// Renamed files are compared with their committed version under the old name
+	oldPaths := make(map[string]string)
+
+	for path, fileDiff := range git.SplitDiff(diff) {
+		// Files without hunks are binary or had their diff omitted as generated
+		if !semantic.Supported(path) || !strings.Contains(fileDiff, "\n@@ ") {
+			continue
+		}
+
+		paths = append(paths, path)
+		oldPaths[path] = path
+
+		for _, line := range strings.Split(fileDiff, "\n") {
+			if old, ok := strings.CutPrefix(line, "rename from "); ok {
+				oldPaths[path] = old
+			}
+
+			if strings.HasPrefix(line, "@@") {
+				break
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	sort.Strings(paths)
+
+	objects := make([]string, 0, 2*len(paths))
+	for _, path := range paths {
+		objects = append(objects, "HEAD:"+oldPaths[path], ":"+path)
+	}
+
+	contents, err := git.CatFiles(ctx, objects)
+	if err != nil {
+		return nil
+	}
+
+	var files []semantic.File
+
+	for _, path := range paths {
+		changes, err := semantic.Compare(path, contents["HEAD:"+oldPaths[path]], contents[":"+path])
+		if err != nil || len(changes) == 0 {
+			continue
+		}
+
+		files = append(files, semantic.File{Path: path, Changes: changes})
+	}
+
+	return files
+}
+
+// apiPaths returns the files whose changes affect a public API.
+func apiPaths(symbols []semantic.File) map[string]bool {
+	paths := make(map[string]bool)
+
+	for _, f := range symbols {
+		if f.APIChange() {
+			paths[f.Path] = true
+		}
+	}
+
+	return paths
+}
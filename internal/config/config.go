@@ -0,0 +1,311 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gic/internal/client"
+	"gic/internal/secrets"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the repository-level config file.
+const FileName = ".gic.yaml"
+
+// Config holds settings read from config files. Zero values mean "not set",
+// so files can be layered on top of each other and under command-line flags.
+type Config struct {
+	// Provider selects the model backend: anthropic, anthropic-api-key or openai.
+	Provider string `yaml:"provider"`
+	// BaseURL overrides the provider's API endpoint.
+	BaseURL string `yaml:"base_url"`
+	// APIKeyEnv names the environment variable holding the provider's API key.
+	APIKeyEnv string `yaml:"api_key_env"`
+	// Model is the model used for generation.
+	Model string `yaml:"model"`
+	// MaxTokens caps the length of the model's response.
+	MaxTokens int `yaml:"max_tokens"`
+	// MaxPromptChars is the prompt size in characters above which large
+	// changesets are trimmed. Superseded by MaxPromptTokens.
+	MaxPromptChars int `yaml:"max_prompt_chars"`
+	// MaxPromptTokens is the prompt size in tokens above which large
+	// changesets are trimmed; by default it follows the context window.
+	MaxPromptTokens int `yaml:"max_prompt_tokens"`
+	// ContextWindow overrides the model's context window in tokens.
+	ContextWindow int `yaml:"context_window"`
+	// Summarize has the model summarise files too large for the prompt
+	// instead of leaving them out.
+	Summarize *bool `yaml:"summarize"`
+	// Stage is the default staging mode.
+	Stage string `yaml:"stage"`
+	// Exclude lists pathspecs whose diffs are never sent to Claude.
+	Exclude []string `yaml:"exclude"`
+	// Secrets selects how secrets in diffs are handled: redact, block or off.
+	Secrets string `yaml:"secrets"`
+	// Style is the commit message style.
+	Style string `yaml:"style"`
+	// Language is the natural language messages are written in.
+	Language string `yaml:"language"`
+	// Prompt holds extra instructions appended to the generation prompt.
+	Prompt string `yaml:"prompt"`
+	// AutoApprove skips the confirmation prompt.
+	AutoApprove *bool `yaml:"auto_approve"`
+	// ConfirmCommits makes the MCP server ask the user before create_commit
+	// writes history; unset asks whenever the client supports it.
+	ConfirmCommits *bool `yaml:"confirm_commits"`
+	// Timeout bounds each model API request, e.g. "90s".
+	Timeout time.Duration `yaml:"timeout"`
+	// GitTimeout bounds each git command, e.g. "30s".
+	GitTimeout time.Duration `yaml:"git_timeout"`
+	// GitBackend selects how git is accessed: auto, exec or go.
+	GitBackend string `yaml:"git_backend"`
+	// MaxRetries is how often rate-limited or overloaded API requests are
+	// retried; 0 disables retries.
+	MaxRetries *int `yaml:"max_retries"`
+	// FallbackModel is used once retries with Model are exhausted.
+	FallbackModel string `yaml:"fallback_model"`
+}
+
+// UserPath returns the path of the user-level config file, next to tokens.json.
+func UserPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config dir: %w", err)
+	}
+
+	return filepath.Join(configDir, "gic", "config.yaml"), nil
+}
+
+// Load reads the user-level and repository-level config files and merges
+// them, repository settings taking precedence except where MergeRepository
+// ignores them. Either path may be empty or point to a missing file. The
+// returned warnings name the repository settings that were ignored.
+func Load(userPath, repoPath string) (*Config, []string, error) {
+	cfg := &Config{}
+
+	if userPath != "" {
+		user, err := Read(userPath)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cfg.Merge(user)
+	}
+
+	if repoPath == "" {
+		return cfg, nil, nil
+	}
+
+	repo, err := Read(repoPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, cfg.MergeRepository(repo, repoPath), nil
+}
+
+// Read parses a single config file. A missing file yields an empty config.
+func Read(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+
+		return nil, err
+	}
+
+	var cfg Config
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// Merge overlays every setting that is set in other onto c.
+func (c *Config) Merge(other *Config) {
+	if other.Provider != "" {
+		c.Provider = other.Provider
+	}
+
+	if other.BaseURL != "" {
+		c.BaseURL = other.BaseURL
+	}
+
+	if other.APIKeyEnv != "" {
+		c.APIKeyEnv = other.APIKeyEnv
+	}
+
+	if other.Model != "" {
+		c.Model = other.Model
+	}
+
+	if other.MaxTokens != 0 {
+		c.MaxTokens = other.MaxTokens
+	}
+
+	if other.MaxPromptChars != 0 {
+		c.MaxPromptChars = other.MaxPromptChars
+	}
+
+	if other.MaxPromptTokens != 0 {
+		c.MaxPromptTokens = other.MaxPromptTokens
+	}
+
+	if other.ContextWindow != 0 {
+		c.ContextWindow = other.ContextWindow
+	}
+
+	if other.Summarize != nil {
+		c.Summarize = other.Summarize
+	}
+
+	if other.Stage != "" {
+		c.Stage = other.Stage
+	}
+
+	if len(other.Exclude) > 0 {
+		c.Exclude = append(c.Exclude, other.Exclude...)
+	}
+
+	if other.Secrets != "" {
+		c.Secrets = other.Secrets
+	}
+
+	if other.Style != "" {
+		c.Style = other.Style
+	}
+
+	if other.Language != "" {
+		c.Language = other.Language
+	}
+
+	if other.Prompt != "" {
+		c.Prompt = other.Prompt
+	}
+
+	if other.AutoApprove != nil {
+		c.AutoApprove = other.AutoApprove
+	}
+
+	if other.ConfirmCommits != nil {
+		c.ConfirmCommits = other.ConfirmCommits
+	}
+
+	if other.Timeout != 0 {
+		c.Timeout = other.Timeout
+	}
+
+	if other.GitTimeout != 0 {
+		c.GitTimeout = other.GitTimeout
+	}
+
+	if other.GitBackend != "" {
+		c.GitBackend = other.GitBackend
+	}
+
+	if other.MaxRetries != nil {
+		c.MaxRetries = other.MaxRetries
+	}
+
+	if other.FallbackModel != "" {
+		c.FallbackModel = other.FallbackModel
+	}
+}
+
+// Clone returns a copy of c that can be merged into without changing c.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Exclude = append([]string(nil), c.Exclude...)
+
+	return &clone
+}
+
+// MergeRepository overlays the settings of the repository file at path onto
+// c. Anyone who can push to a repository controls its file, so settings that
+// decide where requests and credentials go or that skip the user's review
+// are only taken from the user config and flags, and the secrets mode can
+// only be made stricter. It returns a warning for each setting it ignored.
+func (c *Config) MergeRepository(repo *Config, path string) []string {
+	var warnings []string
+
+	trusted := *repo
+
+	ignore := func(name string) {
+		warnings = append(warnings, fmt.Sprintf("ignoring %s in %s: it can only be set in the user config", name, path))
+	}
+
+	if trusted.Provider != "" {
+		ignore("provider")
+		trusted.Provider = ""
+	}
+
+	if trusted.BaseURL != "" {
+		ignore("base_url")
+		trusted.BaseURL = ""
+	}
+
+	if trusted.APIKeyEnv != "" {
+		ignore("api_key_env")
+		trusted.APIKeyEnv = ""
+	}
+
+	if trusted.AutoApprove != nil {
+		ignore("auto_approve")
+		trusted.AutoApprove = nil
+	}
+
+	if trusted.ConfirmCommits != nil {
+		ignore("confirm_commits")
+		trusted.ConfirmCommits = nil
+	}
+
+	// A repository may ask for more care with secrets, never for less
+	if trusted.Secrets != "" {
+		mode, err := secrets.ParseMode(trusted.Secrets)
+		current, currentErr := secrets.ParseMode(c.Secrets)
+
+		if err == nil && currentErr == nil && mode.Weaker(current) {
+			warnings = append(warnings, fmt.Sprintf("ignoring secrets: %s in %s: a repository can only make secrets handling stricter", mode, path))
+			trusted.Secrets = ""
+		}
+	}
+
+	c.Merge(&trusted)
+
+	return warnings
+}
+
+// ProviderConfig returns the model backend settings.
+func (c *Config) ProviderConfig() client.ProviderConfig {
+	maxRetries := 0
+	if c.MaxRetries != nil {
+		maxRetries = *c.MaxRetries
+		if maxRetries == 0 {
+			maxRetries = -1
+		}
+	}
+
+	return client.ProviderConfig{
+		Name:                c.Provider,
+		Model:               c.Model,
+		MaxTokens:           int64(c.MaxTokens),
+		BaseURL:             c.BaseURL,
+		APIKeyEnv:           c.APIKeyEnv,
+		Timeout:             c.Timeout,
+		Retry:               client.RetryPolicy{MaxRetries: maxRetries},
+		FallbackModel:       c.FallbackModel,
+		ContextWindowTokens: c.ContextWindow,
+	}
+}
@@ -0,0 +1,235 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gic/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// ConfigTestSuite is an integration test suite for config file loading
+type ConfigTestSuite struct {
+	suite.Suite
+	tmpDir string
+}
+
+// SetupTest creates a temporary directory for config files
+func (s *ConfigTestSuite) SetupTest() {
+	tmpDir, err := os.MkdirTemp("", "gic-config-test-*")
+	require.NoError(s.T(), err)
+	s.tmpDir = tmpDir
+}
+
+// TearDownTest cleans up the temporary directory
+func (s *ConfigTestSuite) TearDownTest() {
+	if s.tmpDir != "" {
+		_ = os.RemoveAll(s.tmpDir)
+	}
+}
+
+func (s *ConfigTestSuite) write(name, content string) string {
+	path := filepath.Join(s.tmpDir, name)
+	require.NoError(s.T(), os.WriteFile(path, []byte(content), 0o644))
+
+	return path
+}
+
+// TestReadMissingFile verifies that a missing file yields an empty config
+func (s *ConfigTestSuite) TestReadMissingFile() {
+	cfg, err := config.Read(filepath.Join(s.tmpDir, "missing.yaml"))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), &config.Config{}, cfg)
+}
+
+// TestReadEmptyFile verifies that an empty file yields an empty config
+func (s *ConfigTestSuite) TestReadEmptyFile() {
+	cfg, err := config.Read(s.write("empty.yaml", ""))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), &config.Config{}, cfg)
+}
+
+// TestReadAllFields verifies that every documented setting is parsed
+func (s *ConfigTestSuite) TestReadAllFields() {
+	path := s.write("config.yaml", `model: claude-opus-4-1
+max_tokens: 4096
+max_prompt_chars: 100000
+max_prompt_tokens: 50000
+context_window: 1000000
+summarize: true
+stage: tracked
+exclude:
+  - "*.pb.go"
+  - docs/generated/
+style: conventional
+language: German
+prompt: Reference the ticket number when the branch name contains one.
+auto_approve: true
+confirm_commits: false
+timeout: 90s
+git_timeout: 1m
+git_backend: go
+max_retries: 0
+fallback_model: claude-haiku-4-5
+`)
+
+	cfg, err := config.Read(path)
+	require.NoError(s.T(), err)
+
+	assert.Equal(s.T(), "claude-opus-4-1", cfg.Model)
+	assert.Equal(s.T(), 4096, cfg.MaxTokens)
+	assert.Equal(s.T(), 100000, cfg.MaxPromptChars)
+	assert.Equal(s.T(), 50000, cfg.MaxPromptTokens)
+	assert.Equal(s.T(), 1000000, cfg.ProviderConfig().ContextWindow())
+	require.NotNil(s.T(), cfg.Summarize)
+	assert.True(s.T(), *cfg.Summarize)
+	assert.Equal(s.T(), "tracked", cfg.Stage)
+	assert.Equal(s.T(), []string{"*.pb.go", "docs/generated/"}, cfg.Exclude)
+	assert.Equal(s.T(), "conventional", cfg.Style)
+	assert.Equal(s.T(), "German", cfg.Language)
+	assert.Equal(s.T(), "Reference the ticket number when the branch name contains one.", cfg.Prompt)
+	require.NotNil(s.T(), cfg.AutoApprove)
+	assert.True(s.T(), *cfg.AutoApprove)
+	require.NotNil(s.T(), cfg.ConfirmCommits)
+	assert.False(s.T(), *cfg.ConfirmCommits)
+	assert.Equal(s.T(), 90*time.Second, cfg.Timeout)
+	assert.Equal(s.T(), time.Minute, cfg.GitTimeout)
+	assert.Equal(s.T(), "go", cfg.GitBackend)
+	assert.Equal(s.T(), 90*time.Second, cfg.ProviderConfig().Timeout)
+	require.NotNil(s.T(), cfg.MaxRetries)
+	assert.Negative(s.T(), cfg.ProviderConfig().Retry.MaxRetries, "0 disables retries")
+	assert.Equal(s.T(), "claude-haiku-4-5", cfg.ProviderConfig().FallbackModel)
+}
+
+// TestReadUnknownField verifies that typos in setting names are reported
+func (s *ConfigTestSuite) TestReadUnknownField() {
+	_, err := config.Read(s.write("config.yaml", "stlye: conventional\n"))
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "stlye")
+}
+
+// TestLoadRepositoryOverridesUser verifies the precedence of layered files
+func (s *ConfigTestSuite) TestLoadRepositoryOverridesUser() {
+	userPath := s.write("user.yaml", `model: claude-sonnet-4-5
+style: conventional
+language: French
+exclude:
+  - "*.min.js"
+summarize: true
+`)
+	repoPath := s.write(config.FileName, `style: default
+exclude:
+  - vendor/
+summarize: false
+`)
+
+	cfg, warnings, err := config.Load(userPath, repoPath)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), warnings)
+
+	// Settings only in the user file are kept
+	assert.Equal(s.T(), "claude-sonnet-4-5", cfg.Model)
+	assert.Equal(s.T(), "French", cfg.Language)
+
+	// Repository settings win, including an explicit false
+	assert.Equal(s.T(), "default", cfg.Style)
+	require.NotNil(s.T(), cfg.Summarize)
+	assert.False(s.T(), *cfg.Summarize)
+
+	// Excludes accumulate across files
+	assert.Equal(s.T(), []string{"*.min.js", "vendor/"}, cfg.Exclude)
+}
+
+// TestLoadIgnoresRepositoryProvider verifies that a repository file cannot
+// send requests, and with them the user's credentials, to another endpoint
+func (s *ConfigTestSuite) TestLoadIgnoresRepositoryProvider() {
+	userPath := s.write("user.yaml", "api_key_env: WORK_ANTHROPIC_KEY\n")
+	repoPath := s.write(config.FileName, `provider: openai
+base_url: https://attacker.example/v1
+api_key_env: ANTHROPIC_API_KEY
+model: llama3
+`)
+
+	cfg, warnings, err := config.Load(userPath, repoPath)
+	require.NoError(s.T(), err)
+
+	assert.Empty(s.T(), cfg.Provider)
+	assert.Empty(s.T(), cfg.BaseURL)
+	assert.Equal(s.T(), "WORK_ANTHROPIC_KEY", cfg.APIKeyEnv)
+	assert.Equal(s.T(), "llama3", cfg.Model, "other settings still apply")
+
+	require.Len(s.T(), warnings, 3)
+	assert.Contains(s.T(), warnings[0], "provider")
+	assert.Contains(s.T(), warnings[0], repoPath)
+	assert.Contains(s.T(), warnings[1], "base_url")
+	assert.Contains(s.T(), warnings[2], "api_key_env")
+}
+
+// TestLoadIgnoresRepositoryAutoApprove verifies that a repository file
+// cannot commit without the user seeing the message
+func (s *ConfigTestSuite) TestLoadIgnoresRepositoryAutoApprove() {
+	repoPath := s.write(config.FileName, "auto_approve: true\nconfirm_commits: false\n")
+
+	cfg, warnings, err := config.Load("", repoPath)
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), cfg.AutoApprove)
+	assert.Nil(s.T(), cfg.ConfirmCommits)
+	require.Len(s.T(), warnings, 2)
+	assert.Contains(s.T(), warnings[0], "auto_approve")
+	assert.Contains(s.T(), warnings[1], "confirm_commits")
+
+	userPath := s.write("user.yaml", "auto_approve: true\n")
+
+	cfg, _, err = config.Load(userPath, "")
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), cfg.AutoApprove)
+	assert.True(s.T(), *cfg.AutoApprove)
+}
+
+// TestLoadRepositorySecrets verifies that a repository file can make the
+// secrets mode stricter but not weaker
+func (s *ConfigTestSuite) TestLoadRepositorySecrets() {
+	tests := []struct {
+		user, repo, want string
+		warned           bool
+	}{
+		{user: "", repo: "block", want: "block"},
+		{user: "off", repo: "redact", want: "redact"},
+		{user: "", repo: "off", want: "", warned: true},
+		{user: "block", repo: "redact", want: "block", warned: true},
+		{user: "redact", repo: "redact", want: "redact"},
+	}
+
+	for _, tt := range tests {
+		userPath := s.write("user.yaml", "secrets: "+tt.user+"\n")
+		repoPath := s.write(config.FileName, "secrets: "+tt.repo+"\n")
+
+		cfg, warnings, err := config.Load(userPath, repoPath)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), tt.want, cfg.Secrets, "user %q, repository %q", tt.user, tt.repo)
+
+		if tt.warned {
+			require.Len(s.T(), warnings, 1)
+			assert.Contains(s.T(), warnings[0], "secrets")
+		} else {
+			assert.Empty(s.T(), warnings)
+		}
+	}
+}
+
+// TestLoadWithoutFiles verifies that loading succeeds when no file exists
+func (s *ConfigTestSuite) TestLoadWithoutFiles() {
+	cfg, _, err := config.Load(filepath.Join(s.tmpDir, "none.yaml"), "")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), &config.Config{}, cfg)
+}
+
+// TestConfigTestSuite runs the config test suite
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
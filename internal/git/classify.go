@@ -0,0 +1,314 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// FileClass names the kind of file whose diff is summarised in a single line
+// instead of being sent in full. The zero value is ordinary source.
+type FileClass string
+
+const (
+	// ClassGenerated is code written by a tool, e.g. protobuf bindings.
+	ClassGenerated FileClass = "generated"
+	// ClassVendored is third-party code copied into the repository.
+	ClassVendored FileClass = "vendored"
+	// ClassMinified is minified JavaScript or CSS.
+	ClassMinified FileClass = "minified"
+	// ClassSnapshot is a recorded test snapshot.
+	ClassSnapshot FileClass = "snapshot"
+	// ClassNoDiff is a file marked -diff in .gitattributes.
+	ClassNoDiff FileClass = "no-diff"
+)
+
+// lockFiles are dependency lock files. Their diffs are left out entirely.
+var lockFiles = []string{
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"Gemfile.lock",
+	"Cargo.lock",
+	"go.sum",
+	"composer.lock",
+	"Pipfile.lock",
+	"poetry.lock",
+	"mix.lock",
+	"pubspec.lock",
+	"Podfile.lock",
+	"packages.lock.json",
+	"paket.lock",
+}
+
+// generatedSuffixes mark files written by code generators.
+var generatedSuffixes = []string{
+	".pb.go", ".pb.gw.go", ".pb.cc", ".pb.h", "_pb2.py", "_pb2_grpc.py", "_pb.js", "_pb.d.ts",
+	"_generated.go", ".gen.go", ".generated.ts", ".generated.js", ".g.dart", ".freezed.dart",
+}
+
+// minifiedSuffixes mark minified assets and their source maps.
+var minifiedSuffixes = []string{".min.js", ".min.css", ".js.map", ".css.map"}
+
+// minifiedExtensions are checked for minification by line length.
+var minifiedExtensions = []string{".js", ".mjs", ".cjs", ".css"}
+
+// minifiedLineLength is the added line length beyond which a script or
+// stylesheet is treated as minified.
+const minifiedLineLength = 1000
+
+// generatedPatterns match the marker comments code generators write, such as
+// the Go convention "Code generated by <tool>; DO NOT EDIT." (POSIX ERE).
+var generatedPatterns = []string{
+	`^[[:space:]]*(//|#|/?\*|--|;)[[:space:]]*Code generated .* DO NOT EDIT`,
+	`^[[:space:]]*(//|#|/?\*|--|;)[[:space:]]*@generated`,
+}
+
+// generatedMarkers are generatedPatterns compiled to check file headers.
+var generatedMarkers = []*regexp.Regexp{
+	regexp.MustCompile(generatedPatterns[0]),
+	regexp.MustCompile(generatedPatterns[1]),
+}
+
+// commentPrefixes start the comment lines generatedPatterns accept.
+var commentPrefixes = []string{"//", "#", "/*", "*", "--", ";"}
+
+// hasGeneratedHeader reports whether a generator marker appears in the
+// comment block at the top of content, before the first line of code, as the
+// Go convention requires. A marker further down, e.g. in a string or a
+// comment about generated code, does not count.
+func hasGeneratedHeader(content string) bool {
+	for content != "" {
+		var line string
+
+		line, content, _ = strings.Cut(content, "\n")
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		isComment := false
+
+		for _, prefix := range commentPrefixes {
+			if strings.HasPrefix(trimmed, prefix) {
+				isComment = true
+
+				break
+			}
+		}
+
+		if !isComment {
+			return false
+		}
+
+		for _, marker := range generatedMarkers {
+			if marker.MatchString(line) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// classifyPath recognises generated, vendored, minified and snapshot files
+// by their path alone.
+func classifyPath(p string) FileClass {
+	base := path.Base(p)
+	dirs := "/" + path.Dir(p) + "/"
+
+	switch {
+	case strings.Contains(dirs, "/vendor/") || strings.Contains(dirs, "/node_modules/"):
+		return ClassVendored
+	case strings.Contains(dirs, "/__snapshots__/") || strings.HasSuffix(base, ".snap"):
+		return ClassSnapshot
+	case hasAnySuffix(base, minifiedSuffixes):
+		return ClassMinified
+	case hasAnySuffix(base, generatedSuffixes) || strings.HasPrefix(base, "zz_generated"):
+		return ClassGenerated
+	}
+
+	return ""
+}
+
+func hasAnySuffix(s string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isMinified reports whether the diff of a script or stylesheet adds lines
+// too long to be hand-written.
+func isMinified(p, diff string) bool {
+	if !hasAnySuffix(p, minifiedExtensions) {
+		return false
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+") && len(line) > minifiedLineLength {
+			return true
+		}
+	}
+
+	return false
+}
+
+// classifyChunks decides which files of a diff are summarised. The
+// repository's .gitattributes take precedence: linguist-generated and
+// linguist-vendored mark files (or, set to false, unmark them) and -diff
+// hides a file's diff. Otherwise paths, generator marker comments at the top
+// of the staged content and minified lines decide.
+func classifyChunks(ctx context.Context, chunks []diffChunk) (map[string]FileClass, error) {
+	classes := make(map[string]FileClass)
+	if len(chunks) == 0 {
+		return classes, nil
+	}
+
+	paths := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		paths[i] = chunk.path
+	}
+
+	attrs, err := current(ctx).Attributes(ctx, paths, "linguist-generated", "linguist-vendored", "diff")
+	if err != nil {
+		return nil, err
+	}
+
+	var unknown []string
+
+	for _, chunk := range chunks {
+		attr := attrs[chunk.path]
+
+		class := attrClass(attr)
+		if class == "" {
+			class = classifyPath(chunk.path)
+
+			// Files can be marked as ordinary source explicitly
+			if isUnset(attr["linguist-generated"]) && class == ClassGenerated ||
+				isUnset(attr["linguist-vendored"]) && class == ClassVendored {
+				continue
+			}
+		}
+
+		if class == "" && isMinified(chunk.path, chunk.text) {
+			class = ClassMinified
+		}
+
+		switch {
+		case class != "":
+			classes[chunk.path] = class
+		case !isUnset(attr["linguist-generated"]):
+			unknown = append(unknown, chunk.path)
+		}
+	}
+
+	// Grep finds markers anywhere, so only its matches need reading in full
+	candidates, err := current(ctx).GrepStaged(ctx, generatedPatterns, unknown)
+	if err != nil || len(candidates) == 0 {
+		return classes, err
+	}
+
+	objects := make([]string, len(candidates))
+	for i, p := range candidates {
+		objects[i] = ":" + p
+	}
+
+	contents, err := current(ctx).CatFiles(ctx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range candidates {
+		if hasGeneratedHeader(contents[":"+p]) {
+			classes[p] = ClassGenerated
+		}
+	}
+
+	return classes, nil
+}
+
+// attrClass returns the class set through gitattributes, if any.
+func attrClass(attr map[string]string) FileClass {
+	switch {
+	case attr["diff"] == "unset":
+		return ClassNoDiff
+	case isSet(attr["linguist-generated"]):
+		return ClassGenerated
+	case isSet(attr["linguist-vendored"]):
+		return ClassVendored
+	}
+
+	return ""
+}
+
+func isSet(value string) bool {
+	return value == "set" || value == "true"
+}
+
+func isUnset(value string) bool {
+	return value == "unset" || value == "false"
+}
+
+// summarizeClassified replaces the diffs of classified files with a single
+// line naming the class and the size of the change.
+func summarizeClassified(ctx context.Context, diff string) (string, error) {
+	chunks := splitChunks(diff)
+
+	classes, err := classifyChunks(ctx, chunks)
+	if err != nil {
+		return "", err
+	}
+
+	if len(classes) == 0 {
+		return diff, nil
+	}
+
+	var b strings.Builder
+
+	for _, chunk := range chunks {
+		class, ok := classes[chunk.path]
+		if !ok {
+			b.WriteString(chunk.text)
+
+			continue
+		}
+
+		header, _, _ := strings.Cut(chunk.text, "\n")
+		b.WriteString(header + "\n")
+		b.WriteString(omittedNote(class, chunk.text) + "\n")
+	}
+
+	return b.String(), nil
+}
+
+// omittedNote is the line that stands in for the diff of a classified file.
+func omittedNote(class FileClass, diff string) string {
+	added, removed := 0, 0
+	inHunks := false
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunks = true
+		case !inHunks:
+			continue
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+
+	if !inHunks {
+		return fmt.Sprintf("[%s file: diff omitted]", class)
+	}
+
+	return fmt.Sprintf("[%s file: +%d -%d lines, diff omitted]", class, added, removed)
+}
@@ -0,0 +1,482 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// grepBatchSize bounds the pathspecs passed to a single git grep.
+const grepBatchSize = 500
+
+// execRepository runs the git binary for every operation.
+type execRepository struct {
+	// dir is the directory git runs in; empty means the current directory.
+	dir  string
+	opts Options
+}
+
+// NewExecRepository returns the repository containing dir, accessed through
+// the git binary with opts. An empty dir means the current directory.
+func NewExecRepository(dir string, opts Options) Repository {
+	return &execRepository{dir: dir, opts: opts}
+}
+
+func (r *execRepository) TopLevel(ctx context.Context) (string, error) {
+	output, err := r.run(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+func (r *execRepository) Status(ctx context.Context) (string, error) {
+	return r.run(ctx, "status", "--porcelain")
+}
+
+func (r *execRepository) HasStagedChanges(ctx context.Context) (bool, error) {
+	output, err := r.run(ctx, "diff", "--cached", "--name-only")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(output) != "", nil
+}
+
+func (r *execRepository) StagedDiff(ctx context.Context, paths []string) (string, error) {
+	// diff --cached [excludes...] -- [paths...]
+	args := []string{"diff", "--cached"}
+	for _, pattern := range r.opts.excludes() {
+		args = append(args, ":(exclude)"+pattern)
+	}
+
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+
+	return r.run(ctx, args...)
+}
+
+func (r *execRepository) DiffStat(ctx context.Context) ([]FileChange, error) {
+	output, err := r.run(ctx, "diff", "--cached", "-z", "--raw", "--numstat", "-M")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseDiffStat(output), nil
+}
+
+// parseDiffStat parses NUL-separated --raw records followed by --numstat
+// records. Paths are separate fields, so spaces and renames need no quoting.
+func parseDiffStat(output string) []FileChange {
+	fields := strings.Split(output, "\x00")
+
+	var stats []FileChange
+
+	index := make(map[string]int)
+
+	// next returns the following field, or "" at the end of the output
+	next := func(i *int) string {
+		*i++
+		if *i < len(fields) {
+			return fields[*i]
+		}
+
+		return ""
+	}
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+
+		if strings.HasPrefix(field, ":") {
+			// :<old mode> <new mode> <old sha> <new sha> <status>
+			raw := strings.Fields(field[1:])
+			if len(raw) < 5 {
+				continue
+			}
+
+			stat := FileChange{Status: fileStatuses[raw[4][0]], OldMode: raw[0], NewMode: raw[1]}
+			if stat.Status == "" {
+				stat.Status = StatusModified
+			}
+
+			if stat.Status == StatusRenamed || stat.Status == StatusCopied {
+				stat.OldPath = next(&i)
+			}
+
+			stat.Path = next(&i)
+
+			// Added and deleted files have an all-zero mode on the missing side
+			if stat.OldMode == "000000" {
+				stat.OldMode = ""
+			}
+
+			if stat.NewMode == "000000" {
+				stat.NewMode = ""
+			}
+
+			index[stat.Path] = len(stats)
+			stats = append(stats, stat)
+
+			continue
+		}
+
+		// <added> TAB <removed> TAB <path>, with an empty path followed by
+		// the old and new paths for renames and copies
+		counts := strings.SplitN(field, "\t", 3)
+		if len(counts) < 3 {
+			continue
+		}
+
+		path := counts[2]
+		if path == "" {
+			next(&i)
+			path = next(&i)
+		}
+
+		j, ok := index[path]
+		if !ok {
+			index[path] = len(stats)
+			j = len(stats)
+			stats = append(stats, FileChange{Path: path, Status: StatusModified})
+		}
+
+		if counts[0] == "-" && counts[1] == "-" {
+			stats[j].Binary = true
+
+			continue
+		}
+
+		stats[j].Added, _ = strconv.Atoi(counts[0])
+		stats[j].Removed, _ = strconv.Atoi(counts[1])
+	}
+
+	return stats
+}
+
+func (r *execRepository) CatFiles(ctx context.Context, objects []string) (map[string]string, error) {
+	contents := make(map[string]string, len(objects))
+	if len(objects) == 0 {
+		return contents, nil
+	}
+
+	output, err := r.runInput(ctx, strings.NewReader(strings.Join(objects, "\n")+"\n"), "cat-file", "--batch=%(objectname) %(objecttype) %(objectsize)")
+	if err != nil {
+		return nil, err
+	}
+
+	// Each object is "<oid> <type> <size>\n<content>\n", or "<name> missing\n"
+	// where the name may contain spaces
+	for _, object := range objects {
+		header, rest, found := strings.Cut(output, "\n")
+		if !found {
+			break
+		}
+
+		output = rest
+
+		if header == object+" missing" || header == object+" ambiguous" {
+			continue
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("git cat-file returned malformed output for %s", object)
+		}
+
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size > len(output) {
+			return nil, fmt.Errorf("git cat-file returned malformed output for %s", object)
+		}
+
+		if fields[1] == "blob" {
+			contents[object] = output[:size]
+		}
+
+		output = strings.TrimPrefix(output[size:], "\n")
+	}
+
+	return contents, nil
+}
+
+func (r *execRepository) Attributes(ctx context.Context, paths []string, names ...string) (map[string]map[string]string, error) {
+	// Diff paths are relative to the top of the working tree
+	top, err := r.TopLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"-C", top, "check-attr", "-z", "--stdin"}, names...)
+
+	output, err := r.runInput(ctx, strings.NewReader(strings.Join(paths, "\x00")+"\x00"), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// With -z, each result is "<path> NUL <attribute> NUL <value> NUL"
+	fields := strings.Split(output, "\x00")
+
+	result := make(map[string]map[string]string, len(paths))
+
+	for i := 0; i+2 < len(fields); i += 3 {
+		p, attr, value := fields[i], fields[i+1], fields[i+2]
+
+		if result[p] == nil {
+			result[p] = make(map[string]string, len(names))
+		}
+
+		result[p][attr] = value
+	}
+
+	return result, nil
+}
+
+func (r *execRepository) GrepStaged(ctx context.Context, patterns, paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	top, err := r.TopLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+
+	for start := 0; start < len(paths); start += grepBatchSize {
+		batch := paths[start:min(start+grepBatchSize, len(paths))]
+
+		args := []string{"-C", top, "grep", "--cached", "--full-name", "-l", "-I", "-E"}
+		for _, pattern := range patterns {
+			args = append(args, "-e", pattern)
+		}
+
+		args = append(args, "--")
+		for _, p := range batch {
+			args = append(args, ":(literal)"+p)
+		}
+
+		output, err := r.run(ctx, args...)
+		if err != nil {
+			// Exit status 1 means nothing matched
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+			if line != "" {
+				matched = append(matched, line)
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+func (r *execRepository) Log(ctx context.Context, n int) (string, error) {
+	output, err := r.run(ctx, "log", "-"+strconv.Itoa(n), "--oneline")
+	if err != nil && strings.Contains(err.Error(), "does not have any commits yet") {
+		return "", nil
+	}
+
+	return output, err
+}
+
+func (r *execRepository) Add(ctx context.Context, opts AddOptions) error {
+	args := []string{"add"}
+
+	if opts.All {
+		args = append(args, "--all")
+	}
+
+	if opts.Update {
+		args = append(args, "--update")
+	}
+
+	if len(opts.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Paths...)
+	}
+
+	_, err := r.run(ctx, args...)
+
+	return err
+}
+
+func (r *execRepository) Commit(ctx context.Context, message string, amend bool) error {
+	args := []string{"commit", "-m", message}
+	if amend {
+		args = []string{"commit", "--amend", "-m", message}
+	}
+
+	_, err := r.run(ctx, args...)
+
+	return err
+}
+
+func (r *execRepository) LastCommitAuthor(ctx context.Context) (name, email string, err error) {
+	output, err := r.run(ctx, "log", "-1", "--format=%an|%ae")
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.Split(strings.TrimSpace(output), "|")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("unexpected author format: %s", output)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+func (r *execRepository) IsAheadOfRemote(ctx context.Context) (bool, error) {
+	output, err := r.run(ctx, "status", "-sb")
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(output, "ahead"), nil
+}
+
+func (r *execRepository) CompareBranch(ctx context.Context, maxCommits int) (BranchChanges, error) {
+	// A detached HEAD has no name and is compared like any branch
+	branch, _ := r.run(ctx, "symbolic-ref", "--quiet", "--short", "HEAD")
+
+	for _, candidate := range append([]string{"@{upstream}"}, baseBranches...) {
+		name, err := r.run(ctx, "rev-parse", "--verify", "--quiet", "--abbrev-ref", "--symbolic-full-name", candidate)
+		name = strings.TrimSpace(name)
+
+		if err != nil || name == "" || name == strings.TrimSpace(branch) {
+			continue
+		}
+
+		base, err := r.run(ctx, "merge-base", "HEAD", candidate)
+		if err != nil {
+			continue
+		}
+
+		base = strings.TrimSpace(base)
+
+		log, err := r.run(ctx, "log", "-"+strconv.Itoa(maxCommits), "--oneline", base+"..HEAD")
+		if err != nil {
+			return BranchChanges{}, err
+		}
+
+		stat, err := r.run(ctx, "diff", "-z", "--raw", "--numstat", "-M", base, "HEAD")
+		if err != nil {
+			return BranchChanges{}, err
+		}
+
+		return BranchChanges{Base: name, Log: log, Files: parseDiffStat(stat)}, nil
+	}
+
+	return BranchChanges{}, nil
+}
+
+func (r *execRepository) WriteTree(ctx context.Context) (string, error) {
+	output, err := r.run(ctx, "write-tree")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(output), nil
+}
+
+func (r *execRepository) ReadTree(ctx context.Context, treeish string) error {
+	_, err := r.run(ctx, "read-tree", treeish)
+	return err
+}
+
+func (r *execRepository) StageFromTree(ctx context.Context, treeish string, paths []string) error {
+	args := append([]string{"reset", "-q", treeish, "--"}, paths...)
+	_, err := r.run(ctx, args...)
+
+	return err
+}
+
+func (r *execRepository) WorktreeDiff(ctx context.Context, paths []string) (string, error) {
+	args := []string{"diff", "--binary"}
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+
+	return r.run(ctx, args...)
+}
+
+func (r *execRepository) UntrackedFiles(ctx context.Context, paths []string) ([]string, error) {
+	args := []string{"ls-files", "--others", "--exclude-standard"}
+	if len(paths) > 0 {
+		args = append(args, "--")
+		args = append(args, paths...)
+	}
+
+	output, err := r.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+
+	return files, nil
+}
+
+func (r *execRepository) ApplyCached(ctx context.Context, patch string) error {
+	_, err := r.runInput(ctx, strings.NewReader(patch), "apply", "--cached", "--whitespace=nowarn", "-")
+	return err
+}
+
+// run executes a git command and returns its output.
+func (r *execRepository) run(ctx context.Context, args ...string) (string, error) {
+	return r.runInput(ctx, nil, args...)
+}
+
+// runInput executes a git command with stdin attached and returns its output.
+// The command is killed when ctx is cancelled or the timeout expires.
+func (r *execRepository) runInput(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	if r.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, r.opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = r.dir
+	cmd.Stdin = stdin
+
+	var stdout, stderr bytes.Buffer
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), ctxErr)
+		}
+
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), stderr.String())
+		}
+
+		return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
+	}
+
+	return stdout.String(), nil
+}
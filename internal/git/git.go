@@ -1,10 +1,8 @@
 package git
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"os/exec"
-	"strconv"
 	"strings"
 )
 
@@ -13,227 +11,261 @@ type FileChange struct {
 	Path    string
 	Added   int
 	Removed int
+	// Status is how the file changed.
+	Status FileStatus
+	// OldPath is the previous path of a renamed or copied file.
+	OldPath string
+	// Binary is set for binary files, which have no line counts.
+	Binary bool
+	// OldMode and NewMode are the file modes, e.g. "100644" or "120000"
+	// for a symlink. They are empty for added and deleted files respectively.
+	OldMode string
+	NewMode string
 }
 
-// Status returns the output of git status.
-func Status() (string, error) {
-	return run("status", "--porcelain")
-}
-
-// Diff returns the output of git diff (staged and unstaged), excluding lock files.
-func Diff() (string, error) {
-	// Common lock files to exclude from diff
-	excludes := []string{
-		":(exclude)package-lock.json",
-		":(exclude)yarn.lock",
-		":(exclude)pnpm-lock.yaml",
-		":(exclude)Gemfile.lock",
-		":(exclude)Cargo.lock",
-		":(exclude)go.sum",
-		":(exclude)composer.lock",
-		":(exclude)Pipfile.lock",
-		":(exclude)poetry.lock",
-		":(exclude)mix.lock",
-		":(exclude)pubspec.lock",
-		":(exclude)Podfile.lock",
-		":(exclude)packages.lock.json",
-		":(exclude)paket.lock",
-	}
+// FileStatus is how a file changed.
+type FileStatus string
 
-	stagedArgs := append([]string{"diff", "--cached"}, excludes...)
+const (
+	StatusModified    FileStatus = "modified"
+	StatusAdded       FileStatus = "added"
+	StatusDeleted     FileStatus = "deleted"
+	StatusRenamed     FileStatus = "renamed"
+	StatusCopied      FileStatus = "copied"
+	StatusTypeChanged FileStatus = "type-changed"
+)
 
-	staged, err := run(stagedArgs...)
-	if err != nil {
-		return "", err
+// fileStatuses maps the status letters of git diff --raw.
+var fileStatuses = map[byte]FileStatus{
+	'M': StatusModified,
+	'A': StatusAdded,
+	'D': StatusDeleted,
+	'R': StatusRenamed,
+	'C': StatusCopied,
+	'T': StatusTypeChanged,
+}
+
+// ModeChanged reports whether the file's mode changed, e.g. when it became
+// executable.
+func (f FileChange) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// Notable reports whether the change is more than edited lines, i.e. Describe
+// tells something the line counts do not.
+func (f FileChange) Notable() bool {
+	return f.Status != StatusModified && f.Status != "" || f.Binary || f.ModeChanged()
+}
+
+// Describe summarises the change in a few words, e.g.
+// "renamed from old.go, +3 -1 lines" or "binary, mode 100644 -> 100755".
+func (f FileChange) Describe() string {
+	var parts []string
+
+	switch f.Status {
+	case StatusRenamed, StatusCopied:
+		parts = append(parts, fmt.Sprintf("%s from %s", f.Status, f.OldPath))
+	case StatusAdded, StatusDeleted, StatusTypeChanged:
+		parts = append(parts, string(f.Status))
 	}
 
-	unstagedArgs := append([]string{"diff"}, excludes...)
+	if f.Binary {
+		parts = append(parts, "binary")
+	} else {
+		parts = append(parts, fmt.Sprintf("+%d -%d lines", f.Added, f.Removed))
+	}
 
-	unstaged, err := run(unstagedArgs...)
-	if err != nil {
-		return "", err
+	if f.ModeChanged() {
+		parts = append(parts, fmt.Sprintf("mode %s -> %s", f.OldMode, f.NewMode))
 	}
 
-	return staged + "\n" + unstaged, nil
+	return strings.Join(parts, ", ")
 }
 
-// DiffStat returns statistics for all changed files (staged and unstaged).
-func DiffStat() ([]FileChange, error) {
-	// Get staged file stats
-	stagedOutput, err := run("diff", "--numstat", "--cached")
-	if err != nil {
-		return nil, err
-	}
+// StageMode selects which worktree changes end up in the commit.
+type StageMode string
+
+const (
+	// StageAuto commits the index as-is when something is staged, and
+	// falls back to StageAll otherwise.
+	StageAuto StageMode = "auto"
+	// StageStaged commits only what is already in the index.
+	StageStaged StageMode = "staged"
+	// StageTracked stages modifications and deletions of tracked files.
+	StageTracked StageMode = "tracked"
+	// StageAll stages every change, including untracked files.
+	StageAll StageMode = "all"
+	// StagePaths stages only the given pathspecs.
+	StagePaths StageMode = "paths"
+)
 
-	// Get unstaged file stats
-	unstagedOutput, err := run("diff", "--numstat")
-	if err != nil {
-		return nil, err
+// StageModes lists the accepted staging modes in display order.
+var StageModes = []StageMode{StageAuto, StageStaged, StageTracked, StageAll, StagePaths}
+
+// ParseStageMode validates a staging mode name. An empty name means StageAuto.
+func ParseStageMode(name string) (StageMode, error) {
+	if name == "" {
+		return StageAuto, nil
 	}
 
-	// Parse both outputs
-	statsMap := make(map[string]*FileChange)
-
-	parseNumstat := func(output string) {
-		lines := strings.Split(strings.TrimSpace(output), "\n")
-		for _, line := range lines {
-			if line == "" {
-				continue
-			}
-
-			parts := strings.Fields(line)
-			if len(parts) < 3 {
-				continue
-			}
-
-			added, _ := strconv.Atoi(parts[0])
-			removed, _ := strconv.Atoi(parts[1])
-			path := parts[2]
-
-			if existing, ok := statsMap[path]; ok {
-				existing.Added += added
-				existing.Removed += removed
-			} else {
-				statsMap[path] = &FileChange{
-					Path:    path,
-					Added:   added,
-					Removed: removed,
-				}
-			}
+	for _, mode := range StageModes {
+		if string(mode) == name {
+			return mode, nil
 		}
 	}
 
-	parseNumstat(stagedOutput)
-	parseNumstat(unstagedOutput)
+	return "", fmt.Errorf("unknown staging mode %q (expected one of: auto, staged, tracked, all, paths)", name)
+}
 
-	// Convert map to slice
-	var stats []FileChange
-	for _, stat := range statsMap {
-		stats = append(stats, *stat)
-	}
+// Stage updates the index according to mode so that it holds exactly the
+// changes to commit. Paths are required for StagePaths and ignored otherwise.
+func Stage(ctx context.Context, mode StageMode, paths []string) error {
+	switch mode {
+	case StageAuto, "":
+		staged, err := HasStagedChanges(ctx)
+		if err != nil {
+			return err
+		}
 
-	return stats, nil
-}
+		if staged {
+			return nil
+		}
 
-// DiffFiles returns the diff for specific files only, excluding lock files.
-func DiffFiles(paths []string) (string, error) {
-	if len(paths) == 0 {
-		return "", nil
-	}
+		return Add(ctx, "--all")
+	case StageStaged:
+		return nil
+	case StageTracked:
+		return Add(ctx, "--update")
+	case StageAll:
+		return Add(ctx, "--all")
+	case StagePaths:
+		if len(paths) == 0 {
+			return fmt.Errorf("staging mode %q requires at least one path", mode)
+		}
 
-	// Common lock files to exclude
-	excludes := []string{
-		":(exclude)package-lock.json",
-		":(exclude)yarn.lock",
-		":(exclude)pnpm-lock.yaml",
-		":(exclude)Gemfile.lock",
-		":(exclude)Cargo.lock",
-		":(exclude)go.sum",
-		":(exclude)composer.lock",
-		":(exclude)Pipfile.lock",
-		":(exclude)poetry.lock",
-		":(exclude)mix.lock",
-		":(exclude)pubspec.lock",
-		":(exclude)Podfile.lock",
-		":(exclude)packages.lock.json",
-		":(exclude)paket.lock",
+		return Add(ctx, append([]string{"--all", "--"}, paths...)...)
+	default:
+		return fmt.Errorf("unknown staging mode %q", mode)
 	}
+}
+
+// HasStagedChanges reports whether the index differs from HEAD.
+func HasStagedChanges(ctx context.Context) (bool, error) {
+	return current(ctx).HasStagedChanges(ctx)
+}
 
-	// Build args: diff --cached [excludes...] -- [paths...]
-	stagedArgs := append([]string{"diff", "--cached"}, excludes...)
-	stagedArgs = append(stagedArgs, "--")
-	stagedArgs = append(stagedArgs, paths...)
+// TopLevel returns the absolute path of the repository's working tree root.
+func TopLevel(ctx context.Context) (string, error) {
+	return current(ctx).TopLevel(ctx)
+}
 
-	staged, err := run(stagedArgs...)
+// Status returns the output of git status.
+func Status(ctx context.Context) (string, error) {
+	return current(ctx).Status(ctx)
+}
+
+// Diff returns the staged diff, i.e. exactly what the next commit will
+// contain, excluding lock files and the repository's excluded paths.
+// Generated, vendored, minified and snapshot files are reduced to a one-line
+// summary each.
+func Diff(ctx context.Context) (string, error) {
+	output, err := current(ctx).StagedDiff(ctx, nil)
 	if err != nil {
 		return "", err
 	}
 
-	// Build args: diff [excludes...] -- [paths...]
-	unstagedArgs := append([]string{"diff"}, excludes...)
-	unstagedArgs = append(unstagedArgs, "--")
-	unstagedArgs = append(unstagedArgs, paths...)
+	return summarizeClassified(ctx, output)
+}
 
-	unstaged, err := run(unstagedArgs...)
+// DiffStat returns statistics for all staged files, with renames detected.
+func DiffStat(ctx context.Context) ([]FileChange, error) {
+	return current(ctx).DiffStat(ctx)
+}
+
+// DiffFiles returns the staged diff for specific files only, excluding lock
+// files and summarising generated ones like Diff.
+func DiffFiles(ctx context.Context, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	output, err := current(ctx).StagedDiff(ctx, paths)
 	if err != nil {
 		return "", err
 	}
 
-	return staged + "\n" + unstaged, nil
+	return summarizeClassified(ctx, output)
+}
+
+// CatFiles reads several blobs at once. Objects are named as in "git show",
+// e.g. "HEAD:main.go" or ":main.go" for the staged version. Objects that do
+// not exist are left out of the result.
+func CatFiles(ctx context.Context, objects []string) (map[string]string, error) {
+	return current(ctx).CatFiles(ctx, objects)
 }
 
 // Log returns recent commit messages (last 10).
 // Returns empty string if no commits exist yet.
-func Log() (string, error) {
-	output, err := run("log", "-10", "--oneline")
-	if err != nil && strings.Contains(err.Error(), "does not have any commits yet") {
-		return "", nil
-	}
-
-	return output, err
+func Log(ctx context.Context) (string, error) {
+	return current(ctx).Log(ctx, 10)
 }
 
-// Add stages files for commit.
-func Add(files ...string) error {
-	args := append([]string{"add"}, files...)
-	_, err := run(args...)
+// Add stages files for commit. Arguments are as for git add; the --all and
+// --update options and the "--" separator are understood.
+func Add(ctx context.Context, files ...string) error {
+	opts, err := parseAddArgs(files)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return current(ctx).Add(ctx, opts)
 }
 
 // Commit creates a commit with the given message.
-func Commit(message string) error {
-	_, err := run("commit", "-m", message)
-	return err
+func Commit(ctx context.Context, message string) error {
+	return current(ctx).Commit(ctx, message, false)
 }
 
-// CommitAmend amends the last commit with a new message.
-func CommitAmend(message string) error {
-	_, err := run("commit", "--amend", "-m", message)
-	return err
+// WriteTree records the current index as a tree object and returns its hash.
+func WriteTree(ctx context.Context) (string, error) {
+	return current(ctx).WriteTree(ctx)
 }
 
-// LastCommitAuthor returns the author name and email of the last commit.
-func LastCommitAuthor() (name, email string, err error) {
-	output, err := run("log", "-1", "--format=%an|%ae")
-	if err != nil {
-		return "", "", err
-	}
-
-	parts := strings.Split(strings.TrimSpace(output), "|")
-	if len(parts) != 2 {
-		return "", "", fmt.Errorf("unexpected author format: %s", output)
-	}
-
-	return parts[0], parts[1], nil
+// ReadTree replaces the index with the contents of tree-ish.
+func ReadTree(ctx context.Context, treeish string) error {
+	return current(ctx).ReadTree(ctx, treeish)
 }
 
-// IsAheadOfRemote checks if the current branch is ahead of remote.
-func IsAheadOfRemote() (bool, error) {
-	output, err := run("status", "-sb")
-	if err != nil {
-		return false, err
+// StageFromTree sets the index entries for paths to their state in tree-ish,
+// leaving the worktree and all other index entries untouched.
+func StageFromTree(ctx context.Context, treeish string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
 	}
 
-	return strings.Contains(output, "ahead"), nil
+	return current(ctx).StageFromTree(ctx, treeish, paths)
 }
 
-// run executes a git command and returns its output.
-func run(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-
-	var stdout, stderr bytes.Buffer
+// CommitAmend amends the last commit with a new message.
+func CommitAmend(ctx context.Context, message string) error {
+	return current(ctx).Commit(ctx, message, true)
+}
 
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// LastCommitAuthor returns the author name and email of the last commit.
+func LastCommitAuthor(ctx context.Context) (name, email string, err error) {
+	return current(ctx).LastCommitAuthor(ctx)
+}
 
-	if err := cmd.Run(); err != nil {
-		if stderr.Len() > 0 {
-			return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), stderr.String())
-		}
+// maxBranchCommits caps how many commits CompareBranch lists.
+const maxBranchCommits = 50
 
-		return "", fmt.Errorf("git %s failed: %w", strings.Join(args, " "), err)
-	}
+// CompareBranch returns the commits and file changes on the current branch
+// since it forked from its upstream or the default branch.
+func CompareBranch(ctx context.Context) (BranchChanges, error) {
+	return current(ctx).CompareBranch(ctx, maxBranchCommits)
+}
 
-	return stdout.String(), nil
+// IsAheadOfRemote checks if the current branch is ahead of remote.
+func IsAheadOfRemote(ctx context.Context) (bool, error) {
+	return current(ctx).IsAheadOfRemote(ctx)
 }
@@ -1,11 +1,14 @@
 package git_test
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gic/internal/git"
 
@@ -19,6 +22,8 @@ type GitTestSuite struct {
 	suite.Suite
 	tmpDir string
 	oldDir string
+	// backend is the git backend under test; empty means the default
+	backend git.Backend
 }
 
 // SetupTest creates a temporary git repository before each test
@@ -50,10 +55,18 @@ func (s *GitTestSuite) SetupTest() {
 	cmd = exec.Command("git", "config", "user.email", "test@example.com")
 	err = cmd.Run()
 	require.NoError(s.T(), err)
+
+	if s.backend != "" {
+		repo, err := git.Open(s.backend, ".", git.Options{})
+		require.NoError(s.T(), err)
+		git.Use(repo)
+	}
 }
 
 // TearDownTest cleans up the temporary repository after each test
 func (s *GitTestSuite) TearDownTest() {
+	git.Use(git.NewExecRepository("", git.Options{}))
+
 	// Return to original directory
 	if s.oldDir != "" {
 		_ = os.Chdir(s.oldDir)
@@ -68,7 +81,7 @@ func (s *GitTestSuite) TearDownTest() {
 // TestStatus verifies that git status returns correct repository state
 func (s *GitTestSuite) TestStatus() {
 	// Initially, status should be empty (no files)
-	status, err := git.Status()
+	status, err := git.Status(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Empty(s.T(), strings.TrimSpace(status))
 
@@ -77,17 +90,17 @@ func (s *GitTestSuite) TestStatus() {
 	require.NoError(s.T(), err)
 
 	// Status should show untracked file
-	status, err = git.Status()
+	status, err = git.Status(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Contains(s.T(), status, "test.txt")
 	assert.Contains(s.T(), status, "??")
 
 	// Stage the file
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
 
 	// Status should show staged file
-	status, err = git.Status()
+	status, err = git.Status(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Contains(s.T(), status, "test.txt")
 	assert.Contains(s.T(), status, "A")
@@ -102,19 +115,19 @@ func (s *GitTestSuite) TestAdd() {
 	require.NoError(s.T(), err)
 
 	// Add single file
-	err = git.Add("file1.txt")
+	err = git.Add(context.Background(), "file1.txt")
 	assert.NoError(s.T(), err)
 
-	status, err := git.Status()
+	status, err := git.Status(context.Background())
 	require.NoError(s.T(), err)
 	assert.Contains(s.T(), status, "file1.txt")
 	assert.Contains(s.T(), status, "A")
 
 	// Add all files
-	err = git.Add(".")
+	err = git.Add(context.Background(), ".")
 	assert.NoError(s.T(), err)
 
-	status, err = git.Status()
+	status, err = git.Status(context.Background())
 	require.NoError(s.T(), err)
 	assert.Contains(s.T(), status, "file2.txt")
 }
@@ -124,13 +137,13 @@ func (s *GitTestSuite) TestDiff() {
 	// Create and commit initial file
 	err := os.WriteFile("test.txt", []byte("initial content"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 
 	// Initially, no diff
-	diff, err := git.Diff()
+	diff, err := git.Diff(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Empty(s.T(), strings.TrimSpace(diff))
 
@@ -138,25 +151,207 @@ func (s *GitTestSuite) TestDiff() {
 	err = os.WriteFile("test.txt", []byte("modified content"), 0644)
 	require.NoError(s.T(), err)
 
-	// Diff should show unstaged changes
-	diff, err = git.Diff()
+	// Diff should not show unstaged changes
+	diff, err = git.Diff(context.Background())
 	assert.NoError(s.T(), err)
-	assert.Contains(s.T(), diff, "test.txt")
-	assert.Contains(s.T(), diff, "-initial content")
-	assert.Contains(s.T(), diff, "+modified content")
+	assert.Empty(s.T(), strings.TrimSpace(diff))
 
 	// Stage the change
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
 
 	// Diff should show staged changes
-	diff, err = git.Diff()
+	diff, err = git.Diff(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Contains(s.T(), diff, "test.txt")
 	assert.Contains(s.T(), diff, "-initial content")
 	assert.Contains(s.T(), diff, "+modified content")
 }
 
+// TestStage verifies that each staging mode stages the expected changes
+func (s *GitTestSuite) TestStage() {
+	err := os.WriteFile("tracked.txt", []byte("v1"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile("other.txt", []byte("v1"), 0644)
+	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), ".")
+	require.NoError(s.T(), err)
+	err = git.Commit(context.Background(), "Initial commit")
+	require.NoError(s.T(), err)
+
+	staged := func() string {
+		out, err := exec.Command("git", "diff", "--cached", "--name-only").Output()
+		require.NoError(s.T(), err)
+
+		return strings.TrimSpace(string(out))
+	}
+
+	reset := func() {
+		require.NoError(s.T(), exec.Command("git", "reset", "-q").Run())
+	}
+
+	err = os.WriteFile("tracked.txt", []byte("v2"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile("other.txt", []byte("v2"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile("untracked.txt", []byte("new"), 0644)
+	require.NoError(s.T(), err)
+
+	// staged: index is left untouched
+	require.NoError(s.T(), git.Stage(context.Background(), git.StageStaged, nil))
+	assert.Empty(s.T(), staged())
+
+	// tracked: modifications only, no untracked files
+	require.NoError(s.T(), git.Stage(context.Background(), git.StageTracked, nil))
+	assert.Equal(s.T(), "other.txt\ntracked.txt", staged())
+	reset()
+
+	// all: everything including untracked files
+	require.NoError(s.T(), git.Stage(context.Background(), git.StageAll, nil))
+	assert.Equal(s.T(), "other.txt\ntracked.txt\nuntracked.txt", staged())
+	reset()
+
+	// paths: only the given pathspecs
+	require.NoError(s.T(), git.Stage(context.Background(), git.StagePaths, []string{"untracked.txt"}))
+	assert.Equal(s.T(), "untracked.txt", staged())
+	assert.Error(s.T(), git.Stage(context.Background(), git.StagePaths, nil))
+
+	// auto: keeps an existing index
+	require.NoError(s.T(), git.Stage(context.Background(), git.StageAuto, nil))
+	assert.Equal(s.T(), "untracked.txt", staged())
+	reset()
+
+	// auto: stages everything when the index is empty
+	require.NoError(s.T(), git.Stage(context.Background(), git.StageAuto, nil))
+	assert.Equal(s.T(), "other.txt\ntracked.txt\nuntracked.txt", staged())
+}
+
+// TestParseStageMode verifies staging mode validation
+func (s *GitTestSuite) TestParseStageMode() {
+	mode, err := git.ParseStageMode("")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), git.StageAuto, mode)
+
+	mode, err = git.ParseStageMode("tracked")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), git.StageTracked, mode)
+
+	_, err = git.ParseStageMode("everything")
+	assert.Error(s.T(), err)
+}
+
+// TestPartialHunkStaging verifies that a single hunk can be staged on its own
+func (s *GitTestSuite) TestPartialHunkStaging() {
+	var lines []string
+	for i := 1; i <= 20; i++ {
+		lines = append(lines, fmt.Sprintf("line%d", i))
+	}
+
+	err := os.WriteFile("test.txt", []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), "test.txt")
+	require.NoError(s.T(), err)
+	err = git.Commit(context.Background(), "Initial commit")
+	require.NoError(s.T(), err)
+
+	// Change two distant lines, producing two hunks
+	lines[1] = "debug leftover"
+	lines[17] = "real change"
+	lines = append(lines[:10], append([]string{"inserted"}, lines[10:]...)...)
+	err = os.WriteFile("test.txt", []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	require.NoError(s.T(), err)
+
+	diff, err := git.WorktreeDiff(context.Background(), nil)
+	require.NoError(s.T(), err)
+
+	files := git.ParsePatch(diff)
+	require.Len(s.T(), files, 1)
+	assert.Equal(s.T(), "test.txt", files[0].Path)
+	require.Len(s.T(), files[0].Hunks, 3)
+	assert.Equal(s.T(), 1, files[0].Hunks[0].Added())
+	assert.Equal(s.T(), 1, files[0].Hunks[0].Removed())
+
+	// Stage only the last two hunks
+	err = git.ApplyCached(context.Background(), files[0].Select([]int{1, 2}))
+	require.NoError(s.T(), err)
+
+	staged, err := git.Diff(context.Background())
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), staged, "+inserted")
+	assert.Contains(s.T(), staged, "+real change")
+	assert.NotContains(s.T(), staged, "debug leftover")
+
+	// The skipped hunk is still pending in the worktree
+	remaining, err := git.WorktreeDiff(context.Background(), nil)
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), remaining, "+debug leftover")
+	assert.NotContains(s.T(), remaining, "real change")
+
+	assert.Empty(s.T(), files[0].Select(nil))
+}
+
+// TestStageFromTree verifies that an index snapshot can be committed in parts
+func (s *GitTestSuite) TestStageFromTree() {
+	err := os.WriteFile("a.txt", []byte("a1"), 0644)
+	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), ".")
+	require.NoError(s.T(), err)
+	err = git.Commit(context.Background(), "Initial commit")
+	require.NoError(s.T(), err)
+
+	err = os.WriteFile("a.txt", []byte("a2"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile("b.txt", []byte("b1"), 0644)
+	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), ".")
+	require.NoError(s.T(), err)
+
+	snapshot, err := git.WriteTree(context.Background())
+	require.NoError(s.T(), err)
+	require.NotEmpty(s.T(), snapshot)
+
+	// Commit b.txt first, then a.txt
+	require.NoError(s.T(), git.ReadTree(context.Background(), "HEAD"))
+	require.NoError(s.T(), git.StageFromTree(context.Background(), snapshot, []string{"b.txt"}))
+
+	stats, err := git.DiffStat(context.Background())
+	require.NoError(s.T(), err)
+	require.Len(s.T(), stats, 1)
+	assert.Equal(s.T(), "b.txt", stats[0].Path)
+	require.NoError(s.T(), git.Commit(context.Background(), "Add b"))
+
+	require.NoError(s.T(), git.StageFromTree(context.Background(), snapshot, []string{"a.txt"}))
+	require.NoError(s.T(), git.Commit(context.Background(), "Update a"))
+
+	// Everything is committed and the worktree is untouched
+	status, err := git.Status(context.Background())
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), strings.TrimSpace(status))
+
+	log, err := git.Log(context.Background())
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), log, "Add b")
+	assert.Contains(s.T(), log, "Update a")
+}
+
+// TestUntrackedFiles verifies untracked file listing
+func (s *GitTestSuite) TestUntrackedFiles() {
+	err := os.WriteFile("new.txt", []byte("new"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile(".gitignore", []byte("ignored.txt\n"), 0644)
+	require.NoError(s.T(), err)
+	err = os.WriteFile("ignored.txt", []byte("ignored"), 0644)
+	require.NoError(s.T(), err)
+
+	files, err := git.UntrackedFiles(context.Background(), nil)
+	assert.NoError(s.T(), err)
+	assert.ElementsMatch(s.T(), []string{".gitignore", "new.txt"}, files)
+
+	files, err = git.UntrackedFiles(context.Background(), []string{"new.txt"})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"new.txt"}, files)
+}
+
 // TestDiffExcludesLockFiles verifies that lock files are excluded from diff
 func (s *GitTestSuite) TestDiffExcludesLockFiles() {
 	// Create and commit initial state
@@ -164,9 +359,9 @@ func (s *GitTestSuite) TestDiffExcludesLockFiles() {
 	require.NoError(s.T(), err)
 	err = os.WriteFile("package-lock.json", []byte(`{"version": "1.0.0"}`), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add(".")
+	err = git.Add(context.Background(), ".")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 
 	// Modify both files
@@ -174,9 +369,11 @@ func (s *GitTestSuite) TestDiffExcludesLockFiles() {
 	require.NoError(s.T(), err)
 	err = os.WriteFile("package-lock.json", []byte(`{"version": "2.0.0"}`), 0644)
 	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), ".")
+	require.NoError(s.T(), err)
 
 	// Get diff
-	diff, err := git.Diff()
+	diff, err := git.Diff(context.Background())
 	assert.NoError(s.T(), err)
 
 	// Should contain code.js but not package-lock.json
@@ -184,6 +381,78 @@ func (s *GitTestSuite) TestDiffExcludesLockFiles() {
 	assert.NotContains(s.T(), diff, "package-lock.json")
 }
 
+// TestDiffExcludesConfiguredPaths verifies that excluded paths belong to the
+// repository they were configured for
+func (s *GitTestSuite) TestDiffExcludesConfiguredPaths() {
+	ctx := context.Background()
+
+	backend := s.backend
+	if backend == "" {
+		backend = git.BackendAuto
+	}
+
+	require.NoError(s.T(), os.MkdirAll("fixtures", 0755))
+	require.NoError(s.T(), os.WriteFile("code.js", []byte("console.log('hello');"), 0644))
+	require.NoError(s.T(), os.WriteFile(filepath.Join("fixtures", "data.json"), []byte("{}"), 0644))
+	require.NoError(s.T(), git.Add(ctx, "."))
+
+	excluding, err := git.Open(backend, ".", git.Options{Exclude: []string{"fixtures/"}})
+	require.NoError(s.T(), err)
+
+	diff, err := git.Diff(git.WithRepository(ctx, excluding))
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), diff, "code.js")
+	assert.NotContains(s.T(), diff, "fixtures/data.json")
+
+	// Other repositories keep their own settings
+	diff, err = git.Diff(ctx)
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), diff, "fixtures/data.json")
+}
+
+// TestDiffSummarizesGeneratedFiles verifies that generated, vendored and
+// minified files are reduced to one line, honouring .gitattributes
+func (s *GitTestSuite) TestDiffSummarizesGeneratedFiles() {
+	files := map[string]string{
+		".gitattributes":          "docs/*.html linguist-generated\nkeep.pb.go linguist-generated=false\n",
+		"main.go":                 "package main\n",
+		"api.pb.go":               "package api\n",
+		"keep.pb.go":              "package keep\n",
+		"mock.go":                 "// Code generated by mockgen. DO NOT EDIT.\n\npackage mock\n",
+		"gen.go":                  "// Package gen runs mockgen.\npackage gen\n\n// Code generated by mockgen. DO NOT EDIT.\nconst header = \"\"\n",
+		"notes.py":                "import os\n# @generated files are skipped\n",
+		"vendor/lib/lib.go":       "package lib\n",
+		"docs/index.html":         "<html></html>\n",
+		"web/app.js":              "var a=" + strings.Repeat("1+", 600) + "1;\n",
+		"__snapshots__/view.snap": "exports[`view`] = `<div/>`;\n",
+	}
+
+	for name, content := range files {
+		require.NoError(s.T(), os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(s.T(), os.WriteFile(name, []byte(content), 0644))
+	}
+
+	require.NoError(s.T(), git.Add(context.Background(), "."))
+
+	diff, err := git.Diff(context.Background())
+	require.NoError(s.T(), err)
+
+	chunks := git.SplitDiff(diff)
+	require.Len(s.T(), chunks, len(files))
+
+	assert.Contains(s.T(), chunks["main.go"], "+package main")
+	assert.Contains(s.T(), chunks["keep.pb.go"], "+package keep")
+	assert.Contains(s.T(), chunks["api.pb.go"], "[generated file: +1 -0 lines, diff omitted]")
+	assert.Contains(s.T(), chunks["mock.go"], "[generated file: +3 -0 lines, diff omitted]")
+	assert.Contains(s.T(), chunks["gen.go"], "+package gen", "markers after the first line of code do not count")
+	assert.Contains(s.T(), chunks["notes.py"], "+import os")
+	assert.Contains(s.T(), chunks["vendor/lib/lib.go"], "[vendored file:")
+	assert.Contains(s.T(), chunks["docs/index.html"], "[generated file:")
+	assert.Contains(s.T(), chunks["web/app.js"], "[minified file:")
+	assert.Contains(s.T(), chunks["__snapshots__/view.snap"], "[snapshot file:")
+	assert.NotContains(s.T(), chunks["mock.go"], "mockgen")
+}
+
 // TestDiffStat verifies that diff statistics are calculated correctly
 func (s *GitTestSuite) TestDiffStat() {
 	// Create and commit initial files
@@ -191,9 +460,9 @@ func (s *GitTestSuite) TestDiffStat() {
 	require.NoError(s.T(), err)
 	err = os.WriteFile("file2.txt", []byte("old\ncontent\n"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add(".")
+	err = git.Add(context.Background(), ".")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 
 	// Modify file1 (add 2 lines)
@@ -203,9 +472,11 @@ func (s *GitTestSuite) TestDiffStat() {
 	// Modify file2 (remove 1 line, add 1 line)
 	err = os.WriteFile("file2.txt", []byte("new\n"), 0644)
 	require.NoError(s.T(), err)
+	err = git.Add(context.Background(), ".")
+	require.NoError(s.T(), err)
 
 	// Get diff stats
-	stats, err := git.DiffStat()
+	stats, err := git.DiffStat(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), stats, 2)
 
@@ -233,6 +504,47 @@ func (s *GitTestSuite) TestDiffStat() {
 	assert.Equal(s.T(), 2, file2Stat.Removed)
 }
 
+// TestDiffStatDetails verifies that renames, binary files, paths with spaces
+// and mode changes are reported
+func (s *GitTestSuite) TestDiffStatDetails() {
+	require.NoError(s.T(), os.WriteFile("old name.txt", []byte("a\nb\nc\nd\ne\nf\n"), 0644))
+	require.NoError(s.T(), os.WriteFile("image.bin", []byte{0, 1, 2}, 0644))
+	require.NoError(s.T(), os.WriteFile("run.sh", []byte("echo hi\n"), 0644))
+	require.NoError(s.T(), git.Add(context.Background(), "."))
+	require.NoError(s.T(), git.Commit(context.Background(), "Initial commit"))
+
+	require.NoError(s.T(), os.Rename("old name.txt", "new name.txt"))
+	require.NoError(s.T(), os.WriteFile("new name.txt", []byte("a\nb\nc\nd\ne\nf\ng\n"), 0644))
+	require.NoError(s.T(), os.WriteFile("image.bin", []byte{0, 3, 4}, 0644))
+	require.NoError(s.T(), os.Chmod("run.sh", 0755))
+	require.NoError(s.T(), git.Add(context.Background(), "--all"))
+
+	stats, err := git.DiffStat(context.Background())
+	require.NoError(s.T(), err)
+
+	byPath := make(map[string]git.FileChange)
+	for _, stat := range stats {
+		byPath[stat.Path] = stat
+	}
+
+	require.Len(s.T(), byPath, 3)
+
+	renamed := byPath["new name.txt"]
+	assert.Equal(s.T(), git.StatusRenamed, renamed.Status)
+	assert.Equal(s.T(), "old name.txt", renamed.OldPath)
+	assert.Equal(s.T(), 1, renamed.Added)
+	assert.Equal(s.T(), "renamed from old name.txt, +1 -0 lines", renamed.Describe())
+
+	binary := byPath["image.bin"]
+	assert.True(s.T(), binary.Binary)
+	assert.Equal(s.T(), "binary", binary.Describe())
+
+	script := byPath["run.sh"]
+	assert.True(s.T(), script.ModeChanged())
+	assert.True(s.T(), script.Notable())
+	assert.Equal(s.T(), "+0 -0 lines, mode 100644 -> 100755", script.Describe())
+}
+
 // TestDiffFiles verifies that diff can be filtered to specific files
 func (s *GitTestSuite) TestDiffFiles() {
 	// Note: This test documents current behavior. The DiffFiles function
@@ -245,9 +557,9 @@ func (s *GitTestSuite) TestDiffFiles() {
 	require.NoError(s.T(), err)
 	err = os.WriteFile("file2.txt", []byte("content2"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add(".")
+	err = git.Add(context.Background(), ".")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 
 	// Modify files
@@ -257,46 +569,46 @@ func (s *GitTestSuite) TestDiffFiles() {
 	require.NoError(s.T(), err)
 
 	// Test with empty list (should work)
-	diff, err := git.DiffFiles([]string{})
+	diff, err := git.DiffFiles(context.Background(), []string{})
 	assert.NoError(s.T(), err)
 	assert.Empty(s.T(), strings.TrimSpace(diff))
 
 	// Test with file paths - this currently has issues with pathspec excludes
 	// so we just verify it doesn't panic and can be called
-	_, _ = git.DiffFiles([]string{"file1.txt"})
-	_, _ = git.DiffFiles([]string{"file1.txt", "file2.txt"})
+	_, _ = git.DiffFiles(context.Background(), []string{"file1.txt"})
+	_, _ = git.DiffFiles(context.Background(), []string{"file1.txt", "file2.txt"})
 }
 
 // TestLog verifies that commit history is retrieved correctly
 func (s *GitTestSuite) TestLog() {
 	// Initially, no commits (should return empty, not error)
-	log, err := git.Log()
+	log, err := git.Log(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Empty(s.T(), strings.TrimSpace(log))
 
 	// Create first commit
 	err = os.WriteFile("file1.txt", []byte("content1"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("file1.txt")
+	err = git.Add(context.Background(), "file1.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("First commit")
+	err = git.Commit(context.Background(), "First commit")
 	require.NoError(s.T(), err)
 
 	// Log should show one commit
-	log, err = git.Log()
+	log, err = git.Log(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Contains(s.T(), log, "First commit")
 
 	// Create second commit
 	err = os.WriteFile("file2.txt", []byte("content2"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("file2.txt")
+	err = git.Add(context.Background(), "file2.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Second commit")
+	err = git.Commit(context.Background(), "Second commit")
 	require.NoError(s.T(), err)
 
 	// Log should show both commits
-	log, err = git.Log()
+	log, err = git.Log(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Contains(s.T(), log, "First commit")
 	assert.Contains(s.T(), log, "Second commit")
@@ -307,20 +619,20 @@ func (s *GitTestSuite) TestCommit() {
 	// Create and stage a file
 	err := os.WriteFile("test.txt", []byte("content"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
 
 	// Create commit
-	err = git.Commit("Test commit message")
+	err = git.Commit(context.Background(), "Test commit message")
 	assert.NoError(s.T(), err)
 
 	// Verify commit was created
-	log, err := git.Log()
+	log, err := git.Log(context.Background())
 	require.NoError(s.T(), err)
 	assert.Contains(s.T(), log, "Test commit message")
 
 	// Status should be clean
-	status, err := git.Status()
+	status, err := git.Status(context.Background())
 	require.NoError(s.T(), err)
 	assert.Empty(s.T(), strings.TrimSpace(status))
 }
@@ -330,22 +642,22 @@ func (s *GitTestSuite) TestCommitAmend() {
 	// Create initial commit
 	err := os.WriteFile("test.txt", []byte("content"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial message")
+	err = git.Commit(context.Background(), "Initial message")
 	require.NoError(s.T(), err)
 
 	// Verify initial commit
-	log, err := git.Log()
+	log, err := git.Log(context.Background())
 	require.NoError(s.T(), err)
 	assert.Contains(s.T(), log, "Initial message")
 
 	// Amend with new message
-	err = git.CommitAmend("Amended message")
+	err = git.CommitAmend(context.Background(), "Amended message")
 	assert.NoError(s.T(), err)
 
 	// Verify commit was amended
-	log, err = git.Log()
+	log, err = git.Log(context.Background())
 	require.NoError(s.T(), err)
 	assert.Contains(s.T(), log, "Amended message")
 	assert.NotContains(s.T(), log, "Initial message")
@@ -356,13 +668,13 @@ func (s *GitTestSuite) TestLastCommitAuthor() {
 	// Create a commit
 	err := os.WriteFile("test.txt", []byte("content"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Test commit")
+	err = git.Commit(context.Background(), "Test commit")
 	require.NoError(s.T(), err)
 
 	// Get author info
-	name, email, err := git.LastCommitAuthor()
+	name, email, err := git.LastCommitAuthor(context.Background())
 	assert.NoError(s.T(), err)
 	assert.Equal(s.T(), "Test User", name)
 	assert.Equal(s.T(), "test@example.com", email)
@@ -373,13 +685,13 @@ func (s *GitTestSuite) TestIsAheadOfRemote() {
 	// Create initial commit
 	err := os.WriteFile("test.txt", []byte("content"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test.txt")
+	err = git.Add(context.Background(), "test.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 
 	// Without remote, should not be ahead
-	ahead, err := git.IsAheadOfRemote()
+	ahead, err := git.IsAheadOfRemote(context.Background())
 	assert.NoError(s.T(), err)
 	assert.False(s.T(), ahead)
 
@@ -413,25 +725,164 @@ func (s *GitTestSuite) TestIsAheadOfRemote() {
 	}
 
 	// After push, should not be ahead
-	ahead, err = git.IsAheadOfRemote()
+	ahead, err = git.IsAheadOfRemote(context.Background())
 	assert.NoError(s.T(), err)
 	assert.False(s.T(), ahead)
 
 	// Create another local commit
 	err = os.WriteFile("test2.txt", []byte("content2"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("test2.txt")
+	err = git.Add(context.Background(), "test2.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Second commit")
+	err = git.Commit(context.Background(), "Second commit")
 	require.NoError(s.T(), err)
 
 	// Now should be ahead
-	ahead, err = git.IsAheadOfRemote()
+	ahead, err = git.IsAheadOfRemote(context.Background())
 	assert.NoError(s.T(), err)
 	assert.True(s.T(), ahead)
 }
 
+// TestCompareBranch verifies that a branch is compared with where it forked
+// from its upstream, or else from the default branch
+func (s *GitTestSuite) TestCompareBranch() {
+	run := func(args ...string) {
+		output, err := exec.Command("git", args...).CombinedOutput()
+		require.NoError(s.T(), err, "git %v failed: %s", args, output)
+	}
+
+	commit := func(file, message string) {
+		require.NoError(s.T(), os.WriteFile(file, []byte(message+"\n"), 0644))
+		require.NoError(s.T(), git.Add(context.Background(), file))
+		require.NoError(s.T(), git.Commit(context.Background(), message))
+	}
+
+	changes, err := git.CompareBranch(context.Background())
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), changes.Base, "nothing to compare before the first commit")
+
+	commit("base.txt", "Initial commit")
+	run("branch", "-M", "main")
+	run("checkout", "-q", "-b", "dev")
+	commit("dev.txt", "Dev commit")
+	run("checkout", "-q", "-b", "feature")
+	commit("a.txt", "Feature one")
+	commit("b.txt", "Feature two")
+
+	changes, err = git.CompareBranch(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "main", changes.Base)
+	assert.Contains(s.T(), changes.Log, "Dev commit")
+	assert.Contains(s.T(), changes.Log, "Feature two")
+	assert.NotContains(s.T(), changes.Log, "Initial commit")
+	assert.Len(s.T(), changes.Files, 3)
+
+	run("branch", "-q", "--set-upstream-to=dev")
+
+	changes, err = git.CompareBranch(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "dev", changes.Base)
+	assert.Contains(s.T(), changes.Log, "Feature one")
+	assert.NotContains(s.T(), changes.Log, "Dev commit")
+	require.Len(s.T(), changes.Files, 2)
+	assert.Equal(s.T(), "a.txt", changes.Files[0].Path)
+	assert.Equal(s.T(), git.StatusAdded, changes.Files[0].Status)
+	assert.Equal(s.T(), 1, changes.Files[0].Added)
+
+	run("checkout", "-q", "main")
+
+	changes, err = git.CompareBranch(context.Background())
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), changes.Base, "the default branch is not compared with itself")
+	assert.Empty(s.T(), changes.Log)
+}
+
+// TestCatFiles verifies that committed and staged blobs are read in one
+// batch and missing objects are left out
+func (s *GitTestSuite) TestCatFiles() {
+	require.NoError(s.T(), os.WriteFile("main.go", []byte("package main\n"), 0644))
+	require.NoError(s.T(), git.Add(context.Background(), "main.go"))
+	require.NoError(s.T(), git.Commit(context.Background(), "Add main"))
+
+	require.NoError(s.T(), os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(s.T(), os.WriteFile("my file.go", []byte("package main\n"), 0644))
+	require.NoError(s.T(), git.Add(context.Background(), "main.go", "my file.go"))
+
+	// A missing path with a space must not be mistaken for an object header
+	contents, err := git.CatFiles(context.Background(), []string{"HEAD:main.go", "HEAD:my file.go", ":main.go", "HEAD:missing.go", ":my file.go"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{
+		"HEAD:main.go": "package main\n",
+		":main.go":     "package main\n\nfunc main() {}\n",
+		":my file.go":  "package main\n",
+	}, contents)
+}
+
+// TestCancelledContext verifies that git commands honour cancellation and
+// the configured timeout
+func (s *GitTestSuite) TestCancelledContext() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := git.Status(ctx)
+	require.Error(s.T(), err)
+	assert.ErrorIs(s.T(), err, context.Canceled)
+
+	backend := s.backend
+	if backend == "" {
+		backend = git.BackendAuto
+	}
+
+	limited, err := git.Open(backend, ".", git.Options{Timeout: time.Nanosecond})
+	require.NoError(s.T(), err)
+
+	_, err = git.Status(git.WithRepository(context.Background(), limited))
+	require.Error(s.T(), err)
+	assert.ErrorIs(s.T(), err, context.DeadlineExceeded)
+
+	// The timeout belongs to the repository it was set for
+	_, err = git.Status(context.Background())
+	require.NoError(s.T(), err)
+}
+
+// TestOpenWorktree verifies that a repository opened from another directory
+// is validated and can be attached to a context
+func (s *GitTestSuite) TestOpenWorktree() {
+	ctx := context.Background()
+
+	backend := s.backend
+	if backend == "" {
+		backend = git.BackendAuto
+	}
+
+	outside, err := os.MkdirTemp("", "gic-outside-*")
+	require.NoError(s.T(), err)
+	defer func() { _ = os.RemoveAll(outside) }()
+
+	_, err = git.OpenWorktree(ctx, backend, outside, git.Options{})
+	assert.ErrorContains(s.T(), err, "not inside a git working tree")
+
+	require.NoError(s.T(), os.MkdirAll(filepath.Join("sub", "dir"), 0755))
+	require.NoError(s.T(), os.WriteFile("tracked.txt", []byte("tracked"), 0644))
+
+	// Leave the repository and reach it through a subdirectory instead
+	require.NoError(s.T(), os.Chdir(outside))
+
+	repo, err := git.OpenWorktree(ctx, backend, filepath.Join(s.tmpDir, "sub", "dir"), git.Options{})
+	require.NoError(s.T(), err)
+
+	status, err := git.Status(git.WithRepository(ctx, repo))
+	require.NoError(s.T(), err)
+	assert.Contains(s.T(), status, "tracked.txt")
+}
+
 // TestSuite runs the git integration test suite
 func TestGitIntegration(t *testing.T) {
 	suite.Run(t, new(GitTestSuite))
 }
+
+// TestGoBackendIntegration runs the integration test suite against the
+// in-process backend
+func TestGoBackendIntegration(t *testing.T) {
+	suite.Run(t, &GitTestSuite{backend: git.BackendGo})
+}
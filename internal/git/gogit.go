@@ -0,0 +1,1379 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// goRepository reads and writes the repository in-process with go-git.
+type goRepository struct {
+	once sync.Once
+	// dir is the directory the repository is opened from on first use.
+	dir  string
+	repo *gogit.Repository
+	// prefix is the path of dir relative to the top of the working tree,
+	// which pathspecs are resolved against.
+	prefix string
+	err    error
+	opts   Options
+}
+
+// NewGoRepository returns r accessed in-process with opts. Repositories
+// created with memory storage and an in-memory worktree work as well as ones
+// on disk.
+func NewGoRepository(r *gogit.Repository, opts Options) Repository {
+	return &goRepository{repo: r, opts: opts}
+}
+
+// OpenGoRepository returns the repository containing dir, accessed
+// in-process with opts.
+func OpenGoRepository(dir string, opts Options) (Repository, error) {
+	g := &goRepository{dir: dir, opts: opts}
+	if _, _, err := g.open(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// open returns the repository and its worktree, opening the repository on
+// first use.
+func (g *goRepository) open(ctx context.Context) (*gogit.Repository, *gogit.Worktree, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	g.once.Do(func() {
+		if g.repo == nil {
+			g.repo, g.prefix, g.err = openWorktree(g.dir)
+		}
+	})
+
+	if g.err != nil {
+		return nil, nil, g.err
+	}
+
+	wt, err := g.repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return g.repo, wt, nil
+}
+
+// limit bounds an operation by the configured timeout. Work in-process stops
+// where it next checks ctx, e.g. before each file it diffs or reads.
+func (g *goRepository) limit(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, g.opts.Timeout)
+}
+
+// openWorktree opens the repository containing dir and returns dir relative
+// to the top of its working tree.
+func openWorktree(dir string) (*gogit.Repository, string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	r, err := gogit.PlainOpenWithOptions(abs, &gogit.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open git repository in %s: %w", abs, err)
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open git repository in %s: %w", abs, err)
+	}
+
+	prefix, err := filepath.Rel(realPath(wt.Filesystem.Root()), realPath(abs))
+	if err != nil || prefix == "." || strings.HasPrefix(prefix, "..") {
+		prefix = ""
+	}
+
+	return r, filepath.ToSlash(prefix), nil
+}
+
+func realPath(p string) string {
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+
+	return p
+}
+
+// resolve turns a pathspec relative to the current directory into one
+// relative to the top of the working tree.
+func (g *goRepository) resolve(pathspec string) string {
+	return path.Join(g.prefix, filepath.ToSlash(pathspec))
+}
+
+// matchAny reports whether p, relative to the top of the working tree,
+// matches any of the pathspecs.
+func (g *goRepository) matchAny(pathspecs []string, p string) bool {
+	for _, pathspec := range pathspecs {
+		if matchPathspec(g.resolve(pathspec), p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPathspec reports whether p matches pathspec as git does by default:
+// the path itself, a directory containing it, or a glob whose wildcards
+// match slashes too.
+func matchPathspec(pathspec, p string) bool {
+	pathspec = strings.TrimSuffix(pathspec, "/")
+
+	switch {
+	case pathspec == "." || pathspec == "":
+		return true
+	case p == pathspec || strings.HasPrefix(p, pathspec+"/"):
+		return true
+	case !strings.ContainsAny(pathspec, "*?["):
+		return false
+	}
+
+	return globRegexp(pathspec).MatchString(p)
+}
+
+// globRegexp translates a pathspec glob into a regular expression.
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+
+	b.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + class + "]")
+
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("(/.*)?$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile(`^` + regexp.QuoteMeta(glob) + `$`)
+	}
+
+	return re
+}
+
+func (g *goRepository) TopLevel(ctx context.Context) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return wt.Filesystem.Root(), nil
+}
+
+func (g *goRepository) Status(ctx context.Context) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	for _, p := range sortedPaths(status) {
+		s := status[p]
+		if s.Staging == gogit.Unmodified && s.Worktree == gogit.Unmodified {
+			continue
+		}
+
+		fmt.Fprintf(&b, "%c%c %s\n", s.Staging, s.Worktree, p)
+	}
+
+	return b.String(), nil
+}
+
+func sortedPaths(status gogit.Status) []string {
+	paths := make([]string, 0, len(status))
+	for p := range status {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (g *goRepository) HasStagedChanges(ctx context.Context) (bool, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	changes, err := stagedChanges(ctx, r)
+	if err != nil {
+		return false, err
+	}
+
+	return len(changes) > 0, nil
+}
+
+func (g *goRepository) StagedDiff(ctx context.Context, paths []string) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := stagedChanges(ctx, r)
+	if err != nil {
+		return "", err
+	}
+
+	var selected object.Changes
+
+	excludes := g.opts.excludes()
+
+	for _, c := range changes {
+		p := changePath(c)
+		if len(paths) > 0 && !g.matchAny(paths, p) || g.matchAny(excludes, p) {
+			continue
+		}
+
+		selected = append(selected, c)
+	}
+
+	return patchText(ctx, selected)
+}
+
+// patchText renders changes as a unified diff.
+func patchText(ctx context.Context, changes object.Changes) (string, error) {
+	if len(changes) == 0 {
+		return "", nil
+	}
+
+	patch, err := changes.PatchContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return patch.String(), nil
+}
+
+func (g *goRepository) DiffStat(ctx context.Context) ([]FileChange, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := stagedChanges(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return changeStats(ctx, changes)
+}
+
+// changeStats describes each of changes like git diff --raw --numstat.
+func changeStats(ctx context.Context, changes object.Changes) ([]FileChange, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	patch, err := changes.PatchContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]FileChange, len(changes))
+
+	for i, fp := range patch.FilePatches() {
+		stats[i] = changeStat(changes[i], fp)
+	}
+
+	return stats, nil
+}
+
+// changeStat describes a change like a record of git diff --raw --numstat.
+func changeStat(c *object.Change, fp fdiff.FilePatch) FileChange {
+	from, to := c.From.TreeEntry.Mode, c.To.TreeEntry.Mode
+
+	stat := FileChange{Path: changePath(c), Status: StatusModified}
+
+	switch {
+	case c.From.Name == "":
+		stat.Status = StatusAdded
+	case c.To.Name == "":
+		stat.Status = StatusDeleted
+	case c.From.Name != c.To.Name:
+		stat.Status = StatusRenamed
+		stat.OldPath = c.From.Name
+	case from != to && (from == filemode.Symlink || to == filemode.Symlink || from == filemode.Submodule || to == filemode.Submodule):
+		stat.Status = StatusTypeChanged
+	}
+
+	if c.From.Name != "" {
+		stat.OldMode = fmt.Sprintf("%06o", uint32(from))
+	}
+
+	if c.To.Name != "" {
+		stat.NewMode = fmt.Sprintf("%06o", uint32(to))
+	}
+
+	if fp.IsBinary() {
+		stat.Binary = true
+
+		return stat
+	}
+
+	for _, chunk := range fp.Chunks() {
+		switch chunk.Type() {
+		case fdiff.Add:
+			stat.Added += countLines(chunk.Content())
+		case fdiff.Delete:
+			stat.Removed += countLines(chunk.Content())
+		}
+	}
+
+	return stat
+}
+
+// countLines counts lines, including a last one without a newline.
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+
+	return n
+}
+
+// changePath is the path of a change after it was made, or the removed path
+// for deletions.
+func changePath(c *object.Change) string {
+	if c.To.Name != "" {
+		return c.To.Name
+	}
+
+	return c.From.Name
+}
+
+// stagedChanges compares HEAD with the index, detecting renames like git
+// diff -M. The index tree is built in memory, so nothing is written to the
+// repository.
+func stagedChanges(ctx context.Context, r *gogit.Repository) (object.Changes, error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	objects := newOverlay(r.Storer)
+
+	to, err := indexTree(objects, idx.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := headTree(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if from == nil {
+		if from, err = indexTree(objects, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return diffTrees(ctx, from, to, true)
+}
+
+// diffTrees compares two trees, returning the changes ordered by path.
+func diffTrees(ctx context.Context, from, to *object.Tree, renames bool) (object.Changes, error) {
+	opts := &object.DiffTreeOptions{}
+	if renames {
+		opts = object.DefaultDiffTreeOptions
+	}
+
+	changes, err := object.DiffTreeWithOptions(ctx, from, to, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changePath(changes[i]) < changePath(changes[j])
+	})
+
+	return changes, nil
+}
+
+// headTree returns the tree of the HEAD commit, or nil before the first
+// commit.
+func headTree(r *gogit.Repository) (*object.Tree, error) {
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// resolveTree returns the tree named by tree-ish: a tree hash, or a revision
+// such as HEAD or a branch name.
+func resolveTree(r *gogit.Repository, treeish string) (*object.Tree, error) {
+	if plumbing.IsHash(treeish) {
+		if tree, err := r.TreeObject(plumbing.NewHash(treeish)); err == nil {
+			return tree, nil
+		}
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(treeish))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", treeish, err)
+	}
+
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// indexTree builds the tree objects for index entries in objects and
+// returns the root tree.
+func indexTree(objects storer.EncodedObjectStorer, entries []*index.Entry) (*object.Tree, error) {
+	hash, err := writeTree(objects, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(objects, hash)
+}
+
+// writeTree stores the tree objects for index entries, as git write-tree
+// does, and returns the hash of the root tree.
+func writeTree(objects storer.EncodedObjectStorer, entries []*index.Entry) (plumbing.Hash, error) {
+	root := newTreeBuilder()
+
+	for _, e := range entries {
+		// Stage 0 holds merged entries; index.Merged is misnamed
+		if e.Stage != 0 {
+			return plumbing.ZeroHash, fmt.Errorf("%s: index has unmerged entries", e.Name)
+		}
+
+		if e.IntentToAdd {
+			continue
+		}
+
+		root.add(strings.Split(e.Name, "/"), object.TreeEntry{Mode: e.Mode, Hash: e.Hash})
+	}
+
+	return root.write(objects)
+}
+
+// treeBuilder collects the entries of a tree and its subtrees.
+type treeBuilder struct {
+	files map[string]object.TreeEntry
+	dirs  map[string]*treeBuilder
+}
+
+func newTreeBuilder() *treeBuilder {
+	return &treeBuilder{files: make(map[string]object.TreeEntry), dirs: make(map[string]*treeBuilder)}
+}
+
+func (t *treeBuilder) add(parts []string, entry object.TreeEntry) {
+	if len(parts) == 1 {
+		entry.Name = parts[0]
+		t.files[parts[0]] = entry
+
+		return
+	}
+
+	dir, ok := t.dirs[parts[0]]
+	if !ok {
+		dir = newTreeBuilder()
+		t.dirs[parts[0]] = dir
+	}
+
+	dir.add(parts[1:], entry)
+}
+
+func (t *treeBuilder) write(objects storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	entries := make([]object.TreeEntry, 0, len(t.files)+len(t.dirs))
+
+	for _, entry := range t.files {
+		entries = append(entries, entry)
+	}
+
+	for name, dir := range t.dirs {
+		hash, err := dir.write(objects)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+
+	// Git orders tree entries as if directory names ended in a slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+
+		return e.Name
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return sortKey(entries[i]) < sortKey(entries[j])
+	})
+
+	obj := objects.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return objects.SetEncodedObject(obj)
+}
+
+// overlay keeps new objects in memory on top of a repository's storage, so
+// that trees can be built for comparison without writing to the repository.
+type overlay struct {
+	storer.EncodedObjectStorer
+	objects map[plumbing.Hash]plumbing.EncodedObject
+}
+
+func newOverlay(s storer.EncodedObjectStorer) *overlay {
+	return &overlay{EncodedObjectStorer: s, objects: make(map[plumbing.Hash]plumbing.EncodedObject)}
+}
+
+func (o *overlay) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+func (o *overlay) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	o.objects[obj.Hash()] = obj
+
+	return obj.Hash(), nil
+}
+
+func (o *overlay) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if obj, ok := o.objects[h]; ok && (t == plumbing.AnyObject || obj.Type() == t) {
+		return obj, nil
+	}
+
+	return o.EncodedObjectStorer.EncodedObject(t, h)
+}
+
+func (o *overlay) HasEncodedObject(h plumbing.Hash) error {
+	if _, ok := o.objects[h]; ok {
+		return nil
+	}
+
+	return o.EncodedObjectStorer.HasEncodedObject(h)
+}
+
+func (o *overlay) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	if obj, ok := o.objects[h]; ok {
+		return obj.Size(), nil
+	}
+
+	return o.EncodedObjectStorer.EncodedObjectSize(h)
+}
+
+func (g *goRepository) CatFiles(ctx context.Context, objects []string) (map[string]string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	trees := make(map[string]*object.Tree)
+	contents := make(map[string]string, len(objects))
+
+	for _, name := range objects {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rev, p, found := strings.Cut(name, ":")
+		if !found {
+			continue
+		}
+
+		var hash plumbing.Hash
+
+		if rev == "" {
+			entry, err := idx.Entry(p)
+			if err != nil {
+				continue
+			}
+
+			hash = entry.Hash
+		} else {
+			tree, ok := trees[rev]
+			if !ok {
+				// A revision that does not resolve, e.g. HEAD before the
+				// first commit, has no files
+				tree, _ = resolveTree(r, rev)
+				trees[rev] = tree
+			}
+
+			if tree == nil {
+				continue
+			}
+
+			entry, err := tree.FindEntry(p)
+			if err != nil || !entry.Mode.IsFile() {
+				continue
+			}
+
+			hash = entry.Hash
+		}
+
+		content, err := readBlob(r, hash)
+		if err != nil {
+			return nil, err
+		}
+
+		contents[name] = string(content)
+	}
+
+	return contents, nil
+}
+
+func readBlob(r *gogit.Repository, hash plumbing.Hash) ([]byte, error) {
+	blob, err := r.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// builtinAttributes defines the macros git itself provides.
+const builtinAttributes = "[attr]binary -diff -merge -text\n"
+
+func (g *goRepository) Attributes(ctx context.Context, paths []string, names ...string) (map[string]map[string]string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := gitattributes.ReadAttributes(strings.NewReader(builtinAttributes), nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the .gitattributes files on the way to each path apply; parent
+	// directories come first so that deeper files take precedence
+	dirs := map[string]bool{".": true}
+
+	for _, p := range paths {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.Count(sorted[i], "/") < strings.Count(sorted[j], "/") ||
+			strings.Count(sorted[i], "/") == strings.Count(sorted[j], "/") && sorted[i] < sorted[j]
+	})
+
+	for _, dir := range sorted {
+		var domain []string
+		if dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+
+		attrs, err := gitattributes.ReadAttributesFile(wt.Filesystem, domain, ".gitattributes", dir == ".")
+		if err != nil {
+			return nil, err
+		}
+
+		stack = append(stack, attrs...)
+	}
+
+	matcher := gitattributes.NewMatcher(stack)
+	result := make(map[string]map[string]string, len(paths))
+
+	for _, p := range paths {
+		matched, _ := matcher.Match(strings.Split(p, "/"), names)
+
+		values := make(map[string]string, len(names))
+
+		for _, name := range names {
+			attr, ok := matched[name]
+
+			switch {
+			case !ok || attr.IsUnspecified():
+				values[name] = "unspecified"
+			case attr.IsSet():
+				values[name] = "set"
+			case attr.IsUnset():
+				values[name] = "unset"
+			default:
+				values[name] = attr.Value()
+			}
+		}
+
+		result[p] = values
+	}
+
+	return result, nil
+}
+
+// binarySniffLength is how much of a file is checked for NUL bytes to tell
+// binary content apart, as git does.
+const binarySniffLength = 8000
+
+func (g *goRepository) GrepStaged(ctx context.Context, patterns, paths []string) ([]string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*regexp.Regexp, len(patterns))
+
+	for i, pattern := range patterns {
+		re, err := regexp.Compile("(?m)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+
+		res[i] = re
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		entry, err := idx.Entry(p)
+		if err != nil || !entry.Mode.IsFile() {
+			continue
+		}
+
+		content, err := readBlob(r, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.IndexByte(content[:min(len(content), binarySniffLength)], 0) >= 0 {
+			continue
+		}
+
+		for _, re := range res {
+			if re.Match(content) {
+				matched = append(matched, p)
+
+				break
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+func (g *goRepository) Log(ctx context.Context, n int) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	commits, err := r.Log(&gogit.LogOptions{From: head.Hash()})
+	if err != nil {
+		return "", err
+	}
+	defer commits.Close()
+
+	var b strings.Builder
+
+	for i := 0; i < n; i++ {
+		commit, err := commits.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Fprintf(&b, "%s %s\n", commit.Hash.String()[:7], subject)
+	}
+
+	return b.String(), nil
+}
+
+func (g *goRepository) Add(ctx context.Context, opts AddOptions) error {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	if opts.Update {
+		status, err := wt.Status()
+		if err != nil {
+			return err
+		}
+
+		for _, p := range sortedPaths(status) {
+			s := status[p]
+			if s.Worktree == gogit.Unmodified || s.Worktree == gogit.Untracked {
+				continue
+			}
+
+			if len(opts.Paths) > 0 && !g.matchAny(opts.Paths, p) {
+				continue
+			}
+
+			if err := wt.AddWithOptions(&gogit.AddOptions{Path: p}); err != nil {
+				return fmt.Errorf("failed to stage %s: %w", p, err)
+			}
+		}
+
+		return nil
+	}
+
+	if len(opts.Paths) == 0 {
+		if !opts.All {
+			return errors.New("nothing specified, nothing added")
+		}
+
+		return wt.AddWithOptions(&gogit.AddOptions{All: true})
+	}
+
+	for _, p := range opts.Paths {
+		if err := wt.AddWithOptions(&gogit.AddOptions{Path: g.resolve(p)}); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+func (g *goRepository) Commit(ctx context.Context, message string, amend bool) error {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = wt.Commit(cleanupMessage(message), &gogit.CommitOptions{Amend: amend})
+	if errors.Is(err, gogit.ErrEmptyCommit) {
+		return errors.New("nothing to commit")
+	}
+
+	return err
+}
+
+// cleanupMessage tidies a commit message as git commit does by default:
+// trailing whitespace and surrounding blank lines are removed and runs of
+// blank lines collapsed.
+func cleanupMessage(message string) string {
+	var lines []string
+
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+func (g *goRepository) LastCommitAuthor(ctx context.Context) (name, email string, err error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return "", "", err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return "", "", err
+	}
+
+	return commit.Author.Name, commit.Author.Email, nil
+}
+
+func (g *goRepository) IsAheadOfRemote(ctx context.Context) (bool, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, nil
+	}
+
+	if err != nil || !head.Name().IsBranch() {
+		return false, err
+	}
+
+	upstreamName, err := upstreamRef(r, head)
+	if err != nil || upstreamName == "" {
+		return false, err
+	}
+
+	upstream, err := r.Reference(upstreamName, true)
+	if err != nil || upstream.Hash() == head.Hash() {
+		return false, nil
+	}
+
+	local, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return false, err
+	}
+
+	remote, err := r.CommitObject(upstream.Hash())
+	if err != nil {
+		return false, err
+	}
+
+	behind, err := local.IsAncestor(remote)
+	if err != nil {
+		return false, err
+	}
+
+	return !behind, nil
+}
+
+// upstreamRef returns the reference the branch at head tracks, or "" when it
+// tracks none.
+func upstreamRef(r *gogit.Repository, head *plumbing.Reference) (plumbing.ReferenceName, error) {
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	branch, ok := cfg.Branches[head.Name().Short()]
+	if !ok || branch.Remote == "" || branch.Merge == "" {
+		return "", nil
+	}
+
+	if branch.Remote == "." {
+		return branch.Merge, nil
+	}
+
+	return plumbing.NewRemoteReferenceName(branch.Remote, branch.Merge.Short()), nil
+}
+
+func (g *goRepository) CompareBranch(ctx context.Context, maxCommits int) (BranchChanges, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return BranchChanges{}, nil
+	}
+
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	headCommit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	upstream, err := upstreamRef(r, head)
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	candidates := []plumbing.ReferenceName{upstream}
+	for _, name := range baseBranches {
+		if remote, branch, ok := strings.Cut(name, "/"); ok {
+			candidates = append(candidates, plumbing.NewRemoteReferenceName(remote, branch))
+		} else {
+			candidates = append(candidates, plumbing.NewBranchReferenceName(name))
+		}
+	}
+
+	for _, name := range candidates {
+		// origin/HEAD stands for the branch it points to
+		ref, err := r.Reference(name, false)
+		if err == nil && ref.Type() == plumbing.SymbolicReference {
+			name = ref.Target()
+		}
+
+		if name == "" || name == head.Name() {
+			continue
+		}
+
+		ref, err = r.Reference(name, true)
+		if err != nil {
+			continue
+		}
+
+		other, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			continue
+		}
+
+		bases, err := headCommit.MergeBase(other)
+		if err != nil {
+			return BranchChanges{}, err
+		}
+
+		if len(bases) == 0 {
+			continue
+		}
+
+		return branchChanges(ctx, name.Short(), headCommit, bases[0], maxCommits)
+	}
+
+	return BranchChanges{}, nil
+}
+
+// branchChanges lists the commits and changes from base to head.
+func branchChanges(ctx context.Context, name string, head, base *object.Commit, maxCommits int) (BranchChanges, error) {
+	changes := BranchChanges{Base: name}
+
+	// The walk stops at the merge base instead of listing its history
+	commits := object.NewCommitPreorderIter(head, nil, []plumbing.Hash{base.Hash})
+	defer commits.Close()
+
+	var log strings.Builder
+
+	for i := 0; i < maxCommits; i++ {
+		if err := ctx.Err(); err != nil {
+			return BranchChanges{}, err
+		}
+
+		commit, err := commits.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return BranchChanges{}, err
+		}
+
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		fmt.Fprintf(&log, "%s %s\n", commit.Hash.String()[:7], subject)
+	}
+
+	changes.Log = log.String()
+
+	from, err := base.Tree()
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	to, err := head.Tree()
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	diff, err := diffTrees(ctx, from, to, true)
+	if err != nil {
+		return BranchChanges{}, err
+	}
+
+	if changes.Files, err = changeStats(ctx, diff); err != nil {
+		return BranchChanges{}, err
+	}
+
+	return changes, nil
+}
+
+func (g *goRepository) WriteTree(ctx context.Context) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := writeTree(r.Storer, idx.Entries)
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String(), nil
+}
+
+func (g *goRepository) ReadTree(ctx context.Context, treeish string) error {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	idx.Entries = nil
+
+	if treeish != "--empty" {
+		tree, err := resolveTree(r, treeish)
+		if err != nil {
+			return err
+		}
+
+		if idx.Entries, err = treeEntries(tree, nil); err != nil {
+			return err
+		}
+	}
+
+	return r.Storer.SetIndex(idx)
+}
+
+func (g *goRepository) StageFromTree(ctx context.Context, treeish string, paths []string) error {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, _, err := g.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	tree, err := resolveTree(r, treeish)
+	if err != nil {
+		return err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	match := func(p string) bool {
+		return g.matchAny(paths, p)
+	}
+
+	replacements, err := treeEntries(tree, match)
+	if err != nil {
+		return err
+	}
+
+	entries := replacements
+
+	for _, e := range idx.Entries {
+		if !match(e.Name) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	idx.Entries = entries
+
+	return r.Storer.SetIndex(idx)
+}
+
+// treeEntries lists the files of tree as index entries, only those whose
+// path satisfies match when it is not nil.
+func treeEntries(tree *object.Tree, match func(string) bool) ([]*index.Entry, error) {
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	var entries []*index.Entry
+
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Mode == filemode.Dir || match != nil && !match(name) {
+			continue
+		}
+
+		entries = append(entries, &index.Entry{Name: name, Hash: entry.Hash, Mode: entry.Mode})
+	}
+
+	return entries, nil
+}
+
+func (g *goRepository) UntrackedFiles(ctx context.Context, paths []string) ([]string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	_, wt, err := g.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+
+	for _, p := range sortedPaths(status) {
+		if status[p].Worktree != gogit.Untracked || len(paths) > 0 && !g.matchAny(paths, p) {
+			continue
+		}
+
+		// Like git ls-files, list files below the current directory only,
+		// relative to it
+		rel, ok := strings.CutPrefix(p, g.prefix+"/")
+		if g.prefix == "" {
+			rel, ok = p, true
+		}
+
+		if ok {
+			files = append(files, rel)
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,282 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+func (g *goRepository) WorktreeDiff(ctx context.Context, paths []string) (string, error) {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, wt, err := g.open(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := wt.Status()
+	if err != nil {
+		return "", err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+
+	objects := newOverlay(r.Storer)
+
+	from, err := indexTree(objects, idx.Entries)
+	if err != nil {
+		return "", err
+	}
+
+	// The worktree side is the index with changed files replaced by their
+	// current content; untracked files are left out like in git diff
+	entries := make([]*index.Entry, 0, len(idx.Entries))
+
+	for _, e := range idx.Entries {
+		s, ok := status[e.Name]
+
+		switch {
+		case !ok || s.Worktree == gogit.Unmodified || s.Worktree == gogit.Untracked:
+			entries = append(entries, e)
+		case s.Worktree == gogit.Deleted:
+			continue
+		default:
+			entry, err := worktreeEntry(wt, objects, e.Name)
+			if err != nil {
+				return "", err
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	to, err := indexTree(objects, entries)
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := diffTrees(ctx, from, to, false)
+	if err != nil {
+		return "", err
+	}
+
+	if len(paths) > 0 {
+		changes = slices.DeleteFunc(changes, func(c *object.Change) bool {
+			return !g.matchAny(paths, changePath(c))
+		})
+	}
+
+	return patchText(ctx, changes)
+}
+
+// worktreeEntry stores the current content of a worktree file in objects
+// and returns an index entry for it.
+func worktreeEntry(wt *gogit.Worktree, objects storer.EncodedObjectStorer, name string) (*index.Entry, error) {
+	fi, err := wt.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var content []byte
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := wt.Filesystem.Readlink(name)
+		if err != nil {
+			return nil, err
+		}
+
+		content = []byte(target)
+	} else if content, err = util.ReadFile(wt.Filesystem, name); err != nil {
+		return nil, err
+	}
+
+	hash, err := storeBlob(objects, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &index.Entry{Name: name, Hash: hash, Mode: mode}, nil
+}
+
+func storeBlob(objects storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := objects.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return objects.SetEncodedObject(obj)
+}
+
+func (g *goRepository) ApplyCached(ctx context.Context, patch string) error {
+	ctx, cancel := g.limit(ctx)
+	defer cancel()
+
+	r, wt, err := g.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	// Changes without hunks, e.g. to binary files, are staged whole from
+	// the worktree once the hunks are applied
+	var whole []string
+
+	for _, file := range ParsePatch(patch) {
+		if len(file.Hunks) == 0 {
+			whole = append(whole, file.Path)
+
+			continue
+		}
+
+		if err := applyFilePatch(r, idx, file); err != nil {
+			return err
+		}
+	}
+
+	if err := r.Storer.SetIndex(idx); err != nil {
+		return err
+	}
+
+	for _, p := range whole {
+		if err := wt.AddWithOptions(&gogit.AddOptions{Path: p}); err != nil {
+			return fmt.Errorf("failed to stage %s: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// applyFilePatch applies the hunks of a file patch to its index entry.
+func applyFilePatch(r *gogit.Repository, idx *index.Index, file FilePatch) error {
+	entry, err := idx.Entry(file.Path)
+	if err != nil {
+		return fmt.Errorf("%s: does not exist in index", file.Path)
+	}
+
+	old, err := readBlob(r, entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	content, err := applyHunks(string(old), file.Hunks)
+	if err != nil {
+		return fmt.Errorf("patch does not apply to %s: %w", file.Path, err)
+	}
+
+	for _, line := range file.Header {
+		if strings.HasPrefix(line, "deleted file mode ") {
+			_, err := idx.Remove(file.Path)
+			return err
+		}
+
+		if mode, ok := strings.CutPrefix(line, "new mode "); ok {
+			if entry.Mode, err = filemode.New(mode); err != nil {
+				return fmt.Errorf("%s: %w", file.Path, err)
+			}
+		}
+	}
+
+	hash, err := storeBlob(r.Storer, []byte(content))
+	if err != nil {
+		return err
+	}
+
+	// Without stat data the entry is compared with the worktree by content
+	*entry = index.Entry{Name: entry.Name, Hash: hash, Mode: entry.Mode, Size: uint32(len(content))}
+
+	return nil
+}
+
+// applyHunks applies hunks, in order, to content. Every hunk must match
+// exactly at its position.
+func applyHunks(content string, hunks []Hunk) (string, error) {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var (
+		out    []string
+		cursor int
+	)
+
+	for _, h := range hunks {
+		var want, replace []string
+
+		for i, line := range h.Lines {
+			if line == "" || line[0] == '\\' {
+				continue
+			}
+
+			// A following "\ No newline at end of file" marks a last line
+			// without a newline
+			text := line[1:]
+			if i+1 >= len(h.Lines) || !strings.HasPrefix(h.Lines[i+1], "\\") {
+				text += "\n"
+			}
+
+			switch line[0] {
+			case ' ':
+				want = append(want, text)
+				replace = append(replace, text)
+			case '-':
+				want = append(want, text)
+			case '+':
+				replace = append(replace, text)
+			}
+		}
+
+		// Hunks that only add lines start after line OldStart
+		start := h.OldStart - 1
+		if h.OldLines == 0 {
+			start = h.OldStart
+		}
+
+		if start < cursor || start+len(want) > len(lines) || !slices.Equal(lines[start:start+len(want)], want) {
+			return "", fmt.Errorf("hunk %s does not match", strings.TrimSpace(h.Header()))
+		}
+
+		out = append(out, lines[cursor:start]...)
+		out = append(out, replace...)
+		cursor = start + len(want)
+	}
+
+	out = append(out, lines[cursor:]...)
+
+	return strings.Join(out, ""), nil
+}
@@ -0,0 +1,106 @@
+package git_test
+
+import (
+	"context"
+	"testing"
+
+	"gic/internal/git"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRepository makes the package functions act on an empty in-memory
+// repository and returns its worktree
+func memoryRepository(t *testing.T) billy.Filesystem {
+	fs := memfs.New()
+
+	r, err := gogit.Init(memory.NewStorage(), fs)
+	require.NoError(t, err)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+
+	cfg.User.Name = "Test User"
+	cfg.User.Email = "test@example.com"
+	require.NoError(t, r.SetConfig(cfg))
+
+	git.Use(git.NewGoRepository(r, git.Options{}))
+	t.Cleanup(func() { git.Use(git.NewExecRepository("", git.Options{})) })
+
+	return fs
+}
+
+// TestMemoryRepository verifies the commit workflow on a repository that
+// exists only in memory
+func TestMemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	fs := memoryRepository(t)
+
+	require.NoError(t, util.WriteFile(fs, "main.go", []byte("package main\n"), 0644))
+	require.NoError(t, util.WriteFile(fs, "go.sum", []byte("example.com/mod v1.0.0 h1:abc=\n"), 0644))
+	require.NoError(t, git.Stage(ctx, git.StageAuto, nil))
+	require.NoError(t, git.Commit(ctx, "Add main\n\n"))
+
+	log, err := git.Log(ctx)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{7} Add main\n$`, log)
+
+	require.NoError(t, util.WriteFile(fs, "main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+	require.NoError(t, util.WriteFile(fs, "api.pb.go", []byte("package main\n"), 0644))
+	require.NoError(t, fs.Rename("go.sum", "deps.sum"))
+	require.NoError(t, git.Stage(ctx, git.StageAll, nil))
+
+	diff, err := git.Diff(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "diff --git a/main.go b/main.go")
+	assert.Contains(t, diff, "+func main() {}")
+	assert.Contains(t, diff, "[generated file: +1 -0 lines, diff omitted]")
+
+	stats, err := git.DiffStat(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []git.FileChange{
+		{Path: "api.pb.go", Added: 1, Status: git.StatusAdded, NewMode: "100644"},
+		{Path: "deps.sum", Status: git.StatusRenamed, OldPath: "go.sum", OldMode: "100644", NewMode: "100644"},
+		{Path: "main.go", Added: 2, Status: git.StatusModified, OldMode: "100644", NewMode: "100644"},
+	}, stats)
+
+	contents, err := git.CatFiles(ctx, []string{"HEAD:main.go", ":main.go", "HEAD:api.pb.go"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"HEAD:main.go": "package main\n",
+		":main.go":     "package main\n\nfunc main() {}\n",
+	}, contents)
+
+	require.NoError(t, git.Commit(ctx, "Add main function"))
+
+	staged, err := git.HasStagedChanges(ctx)
+	require.NoError(t, err)
+	assert.False(t, staged)
+
+	name, email, err := git.LastCommitAuthor(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Test User", name)
+	assert.Equal(t, "test@example.com", email)
+
+	assert.Error(t, git.Commit(ctx, "Nothing staged"))
+}
+
+// TestParseBackend verifies backend name validation
+func TestParseBackend(t *testing.T) {
+	backend, err := git.ParseBackend("")
+	require.NoError(t, err)
+	assert.Equal(t, git.BackendAuto, backend)
+
+	backend, err = git.ParseBackend("go")
+	require.NoError(t, err)
+	assert.Equal(t, git.BackendGo, backend)
+
+	_, err = git.ParseBackend("libgit2")
+	assert.Error(t, err)
+}
@@ -0,0 +1,269 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@(.*)$`)
+
+// FilePatch is the diff of a single file split into hunks.
+type FilePatch struct {
+	Path      string
+	Header    []string
+	Hunks     []Hunk
+	Untracked bool
+}
+
+// Hunk is a single "@@" section of a file diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Context  string
+	Lines    []string
+}
+
+// Added returns the number of added lines in the hunk.
+func (h Hunk) Added() int {
+	return h.count('+')
+}
+
+// Removed returns the number of removed lines in the hunk.
+func (h Hunk) Removed() int {
+	return h.count('-')
+}
+
+func (h Hunk) count(prefix byte) int {
+	n := 0
+
+	for _, line := range h.Lines {
+		if len(line) > 0 && line[0] == prefix {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Header returns the "@@ -a,b +c,d @@" line of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@%s", h.OldStart, h.OldLines, h.NewStart, h.NewLines, h.Context)
+}
+
+// ParsePatch splits unified diff output into per-file patches.
+func ParsePatch(diff string) []FilePatch {
+	var (
+		files   []FilePatch
+		current *FilePatch
+		hunk    *Hunk
+	)
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+
+		if hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+			hunk = nil
+		}
+
+		files = append(files, *current)
+		current = nil
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+
+			current = &FilePatch{Path: patchPath(line), Header: []string{line}}
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "@@ "):
+			if hunk != nil {
+				current.Hunks = append(current.Hunks, *hunk)
+			}
+
+			hunk = parseHunkHeader(line)
+		case hunk != nil:
+			if line == "" {
+				continue
+			}
+
+			hunk.Lines = append(hunk.Lines, line)
+		default:
+			if strings.HasPrefix(line, "+++ b/") {
+				current.Path = strings.TrimPrefix(line, "+++ b/")
+			}
+
+			current.Header = append(current.Header, line)
+		}
+	}
+
+	flush()
+
+	return files
+}
+
+// SplitDiff splits unified diff output into the unmodified diff text of each
+// file, keyed by path.
+func SplitDiff(diff string) map[string]string {
+	files := make(map[string]string)
+
+	for _, chunk := range splitChunks(diff) {
+		files[chunk.path] = chunk.text
+	}
+
+	return files
+}
+
+// diffChunk is the unmodified diff text of one file.
+type diffChunk struct {
+	path string
+	text string
+}
+
+// splitChunks splits unified diff output per file, in diff order.
+func splitChunks(diff string) []diffChunk {
+	var (
+		chunks  []diffChunk
+		path    string
+		current strings.Builder
+	)
+
+	flush := func() {
+		if path != "" {
+			chunks = append(chunks, diffChunk{path: path, text: current.String()})
+		}
+
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+
+			path = patchPath(strings.TrimRight(line, "\n"))
+		}
+
+		if path != "" {
+			current.WriteString(line)
+		}
+	}
+
+	flush()
+
+	return chunks
+}
+
+// Select builds a patch containing only the hunks at the given indexes,
+// rewriting hunk headers so the result applies cleanly on its own. Patches
+// without hunks (binary, mode-only) are returned whole for any selection.
+func (f FilePatch) Select(indexes []int) string {
+	if len(indexes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	for _, line := range f.Header {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+
+	if len(f.Hunks) == 0 {
+		return b.String()
+	}
+
+	delta := 0
+
+	for _, i := range indexes {
+		if i < 0 || i >= len(f.Hunks) {
+			continue
+		}
+
+		h := f.Hunks[i]
+		h.NewStart = h.OldStart + delta
+
+		switch {
+		case h.OldLines == 0:
+			h.NewStart++
+		case h.NewLines == 0:
+			h.NewStart--
+		}
+
+		delta += h.NewLines - h.OldLines
+
+		b.WriteString(h.Header())
+		b.WriteString("\n")
+
+		for _, line := range h.Lines {
+			b.WriteString(line)
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
+
+// WorktreeDiff returns the unstaged diff for the given paths (or the whole
+// worktree when paths is empty), in a form that ApplyCached accepts.
+func WorktreeDiff(ctx context.Context, paths []string) (string, error) {
+	return current(ctx).WorktreeDiff(ctx, paths)
+}
+
+// UntrackedFiles lists untracked files that are not ignored.
+func UntrackedFiles(ctx context.Context, paths []string) ([]string, error) {
+	return current(ctx).UntrackedFiles(ctx, paths)
+}
+
+// ApplyCached applies a patch to the index only, leaving the worktree untouched.
+func ApplyCached(ctx context.Context, patch string) error {
+	if strings.TrimSpace(patch) == "" {
+		return nil
+	}
+
+	return current(ctx).ApplyCached(ctx, patch)
+}
+
+// parseHunkHeader parses an "@@ -a,b +c,d @@ context" line.
+func parseHunkHeader(line string) *Hunk {
+	m := hunkHeaderRegex.FindStringSubmatch(line)
+	if m == nil {
+		return &Hunk{Context: strings.TrimPrefix(line, "@@")}
+	}
+
+	atoi := func(s string, def int) int {
+		if s == "" {
+			return def
+		}
+
+		n, _ := strconv.Atoi(s)
+
+		return n
+	}
+
+	return &Hunk{
+		OldStart: atoi(m[1], 0),
+		OldLines: atoi(m[2], 1),
+		NewStart: atoi(m[3], 0),
+		NewLines: atoi(m[4], 1),
+		Context:  m[5],
+	}
+}
+
+// patchPath extracts the destination path from a "diff --git a/x b/x" line.
+func patchPath(line string) string {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return rest[i+3:]
+	}
+
+	return rest
+}
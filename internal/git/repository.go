@@ -0,0 +1,244 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Repository is the git access gic needs. The package-level functions act on
+// the repository attached to their context with WithRepository, or else the
+// one selected with Use, by default the one in the current directory.
+//
+// Paths in arguments are relative to the current directory like on the git
+// command line, while paths in diffs and results are relative to the top of
+// the working tree.
+type Repository interface {
+	// TopLevel returns the absolute path of the working tree root.
+	TopLevel(ctx context.Context) (string, error)
+	// Status returns the working tree status in porcelain format.
+	Status(ctx context.Context) (string, error)
+	// HasStagedChanges reports whether the index differs from HEAD.
+	HasStagedChanges(ctx context.Context) (bool, error)
+	// StagedDiff returns the diff between HEAD and the index with renames
+	// detected, limited to paths when given and leaving out lock files and
+	// the paths excluded by the repository's Options.
+	StagedDiff(ctx context.Context, paths []string) (string, error)
+	// DiffStat returns statistics for all staged files, with renames detected.
+	DiffStat(ctx context.Context) ([]FileChange, error)
+	// CatFiles reads blobs named as in "git show"; missing ones are left out.
+	CatFiles(ctx context.Context, objects []string) (map[string]string, error)
+	// Attributes looks up gitattributes, returning the value of each
+	// attribute ("set", "unset", a string value or "unspecified") by path.
+	Attributes(ctx context.Context, paths []string, names ...string) (map[string]map[string]string, error)
+	// GrepStaged returns the paths whose staged content matches one of the
+	// POSIX extended regular expressions, skipping binary files.
+	GrepStaged(ctx context.Context, patterns, paths []string) ([]string, error)
+	// Log returns the last n commits as "<hash> <subject>" lines, or an
+	// empty string before the first commit.
+	Log(ctx context.Context, n int) (string, error)
+	// Add stages worktree changes.
+	Add(ctx context.Context, opts AddOptions) error
+	// Commit records the index as a new commit, or replaces the last one
+	// when amend is set.
+	Commit(ctx context.Context, message string, amend bool) error
+	// LastCommitAuthor returns the author of the last commit.
+	LastCommitAuthor(ctx context.Context) (name, email string, err error)
+	// IsAheadOfRemote reports whether the current branch has commits that
+	// its upstream does not.
+	IsAheadOfRemote(ctx context.Context) (bool, error)
+	// CompareBranch compares HEAD with where it forked from its upstream,
+	// or else from the default branch, listing up to maxCommits commits.
+	// The result is empty when there is no such branch.
+	CompareBranch(ctx context.Context, maxCommits int) (BranchChanges, error)
+	// WriteTree records the index as a tree object and returns its hash.
+	WriteTree(ctx context.Context) (string, error)
+	// ReadTree replaces the index with the contents of tree-ish, or empties
+	// it for "--empty".
+	ReadTree(ctx context.Context, treeish string) error
+	// StageFromTree sets the index entries for paths to their state in
+	// tree-ish.
+	StageFromTree(ctx context.Context, treeish string, paths []string) error
+	// WorktreeDiff returns the unstaged diff, limited to paths when given.
+	WorktreeDiff(ctx context.Context, paths []string) (string, error)
+	// UntrackedFiles lists untracked files that are not ignored.
+	UntrackedFiles(ctx context.Context, paths []string) ([]string, error)
+	// ApplyCached applies a patch made by WorktreeDiff to the index only.
+	ApplyCached(ctx context.Context, patch string) error
+}
+
+// AddOptions selects the changes Add stages.
+type AddOptions struct {
+	// All stages the whole working tree, including untracked files and
+	// deletions, when no paths are given.
+	All bool
+	// Update stages only modifications and deletions of tracked files.
+	Update bool
+	// Paths limits staging to these pathspecs.
+	Paths []string
+}
+
+// BranchChanges is the work on the current branch that its base branch does
+// not have, i.e. what a pull request from it would contain.
+type BranchChanges struct {
+	// Base names the branch compared with, e.g. "origin/main", or is empty
+	// when none was found.
+	Base string
+	// Log lists the commits since the merge base as "<hash> <subject>"
+	// lines, newest first.
+	Log string
+	// Files are the changes between the merge base and HEAD.
+	Files []FileChange
+}
+
+// baseBranches are tried in order as the branch a pull request targets when
+// the current branch has no upstream.
+var baseBranches = []string{"origin/HEAD", "origin/main", "origin/master", "main", "master"}
+
+// Options are settings of a single repository, e.g. from its .gic.yaml.
+type Options struct {
+	// Exclude lists pathspecs kept out of diffs in addition to lock files.
+	Exclude []string
+	// Timeout bounds each git command, or each operation of the in-process
+	// backend, so that one stuck e.g. on a credential helper cannot block
+	// forever. Zero means no limit.
+	Timeout time.Duration
+}
+
+// excludes returns the pathspecs StagedDiff leaves out.
+func (o Options) excludes() []string {
+	return append(append([]string(nil), lockFiles...), o.Exclude...)
+}
+
+// Backend selects how gic talks to git.
+type Backend string
+
+const (
+	// BackendAuto runs the git binary when it is installed and falls back
+	// to BackendGo otherwise.
+	BackendAuto Backend = "auto"
+	// BackendExec runs the git binary for every operation.
+	BackendExec Backend = "exec"
+	// BackendGo reads and writes the repository in-process, without a git
+	// binary, hooks or the user's git configuration beyond user identity.
+	BackendGo Backend = "go"
+)
+
+// Backends lists the accepted backends in display order.
+var Backends = []Backend{BackendAuto, BackendExec, BackendGo}
+
+// ParseBackend validates a backend name. An empty name means BackendAuto.
+func ParseBackend(name string) (Backend, error) {
+	if name == "" {
+		return BackendAuto, nil
+	}
+
+	for _, backend := range Backends {
+		if string(backend) == name {
+			return backend, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown git backend %q (expected one of: auto, exec, go)", name)
+}
+
+// Open returns the repository containing dir, accessed through backend with
+// opts.
+func Open(backend Backend, dir string, opts Options) (Repository, error) {
+	switch backend {
+	case BackendAuto, "":
+		if !hasGitBinary() {
+			return OpenGoRepository(dir, opts)
+		}
+
+		return NewExecRepository(dir, opts), nil
+	case BackendExec:
+		return NewExecRepository(dir, opts), nil
+	case BackendGo:
+		return OpenGoRepository(dir, opts)
+	default:
+		return nil, fmt.Errorf("unknown git backend %q", backend)
+	}
+}
+
+// OpenWorktree is like Open but fails unless dir is inside a git working
+// tree, so that a mistyped path is reported before anything else runs.
+func OpenWorktree(ctx context.Context, backend Backend, dir string, opts Options) (Repository, error) {
+	r, err := Open(backend, dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git working tree: %w", dir, err)
+	}
+
+	if _, err := r.TopLevel(ctx); err != nil {
+		return nil, fmt.Errorf("%s is not inside a git working tree: %w", dir, err)
+	}
+
+	return r, nil
+}
+
+// repo is the repository the package-level functions act on by default.
+var repo = defaultRepository()
+
+// defaultRepository is the repository in the current directory, accessed
+// through the git binary when it is installed.
+func defaultRepository() Repository {
+	if !hasGitBinary() {
+		return &goRepository{dir: "."}
+	}
+
+	return NewExecRepository("", Options{})
+}
+
+func hasGitBinary() bool {
+	_, err := exec.LookPath("git")
+	return err == nil
+}
+
+// Use makes the package-level functions act on r.
+func Use(r Repository) {
+	repo = r
+}
+
+type repositoryKey struct{}
+
+// WithRepository returns a context that makes the package-level functions act
+// on r instead of the repository selected with Use. Requests for different
+// repositories can then run side by side.
+func WithRepository(ctx context.Context, r Repository) context.Context {
+	return context.WithValue(ctx, repositoryKey{}, r)
+}
+
+// current returns the repository the package-level functions act on.
+func current(ctx context.Context) Repository {
+	if r, ok := ctx.Value(repositoryKey{}).(Repository); ok {
+		return r
+	}
+
+	return repo
+}
+
+// parseAddArgs translates git add arguments into AddOptions.
+func parseAddArgs(args []string) (AddOptions, error) {
+	var opts AddOptions
+
+	for i, arg := range args {
+		switch {
+		case arg == "--":
+			opts.Paths = append(opts.Paths, args[i+1:]...)
+
+			return opts, nil
+		case arg == "--all" || arg == "-A":
+			opts.All = true
+		case arg == "--update" || arg == "-u":
+			opts.Update = true
+		case strings.HasPrefix(arg, "-"):
+			return AddOptions{}, fmt.Errorf("unsupported git add option %q", arg)
+		default:
+			opts.Paths = append(opts.Paths, arg)
+		}
+	}
+
+	return opts, nil
+}
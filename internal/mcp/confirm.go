@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gic/internal/commit"
+	"gic/internal/git"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// confirmCommit shows the proposed message and the staged files to the user
+// through the client, who can accept it, edit the message or cancel. It
+// returns the message to commit, or ok false when the user did not accept.
+// An edited message must still follow style, like one passed to
+// create_commit.
+//
+// Unless confirm_commits is set, the user is asked whenever the client
+// supports elicitation. With confirm_commits: true a client without it is an
+// error, and with false nobody is asked.
+func (s *Server) confirmCommit(ctx context.Context, session *mcp.ServerSession, message string, style commit.Style) (confirmed string, ok bool, err error) {
+	if s.config.ConfirmCommits != nil && !*s.config.ConfirmCommits {
+		return message, true, nil
+	}
+
+	if !supportsElicitation(session) {
+		if s.config.ConfirmCommits != nil {
+			return "", false, fmt.Errorf("commits need confirmation, but the client does not support elicitation")
+		}
+
+		return message, true, nil
+	}
+
+	stats, err := git.DiffStat(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("git diff stat failed: %w", err)
+	}
+
+	var prompt strings.Builder
+
+	prompt.WriteString("Create this commit?\n\n")
+	prompt.WriteString(message)
+	prompt.WriteString("\n\nStaged files:\n")
+
+	for _, stat := range stats {
+		fmt.Fprintf(&prompt, "- %s (%s)\n", stat.Path, stat.Describe())
+	}
+
+	result, err := session.Elicit(ctx, &mcp.ElicitParams{
+		Message: prompt.String(),
+		RequestedSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"message": map[string]any{
+					"type":        "string",
+					"title":       "Commit message",
+					"description": "Accept the message as is or edit it",
+					"default":     message,
+				},
+			},
+			"required": []string{"message"},
+		},
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("confirmation failed: %w", err)
+	}
+
+	if result.Action != "accept" {
+		return "", false, nil
+	}
+
+	if edited, _ := result.Content["message"].(string); strings.TrimSpace(edited) != "" && edited != message {
+		if err := style.Validate(edited); err != nil {
+			return "", false, fmt.Errorf("edited message does not follow the %s style: %w", style, err)
+		}
+
+		message = edited
+	}
+
+	return message, true, nil
+}
+
+// supportsElicitation reports whether the client can ask its user for input.
+func supportsElicitation(session *mcp.ServerSession) bool {
+	if session == nil {
+		return false
+	}
+
+	params := session.InitializeParams()
+
+	return params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil
+}
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// shutdownTimeout bounds how long RunHTTP waits for open requests on exit.
+const shutdownTimeout = 5 * time.Second
+
+// Handler serves the streamable HTTP transport at /mcp and the legacy SSE
+// transport at /sse. Every client shares this server. With a non-empty token,
+// requests must send it as a bearer token. Without one, only requests for a
+// loopback host from a local page, or from no page at all, are served, so
+// that a web page cannot reach the server through DNS rebinding.
+func (s *Server) Handler(token string) http.Handler {
+	getServer := func(*http.Request) *mcp.Server { return s.server }
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(getServer, nil))
+	mux.Handle("/sse", mcp.NewSSEHandler(getServer, nil))
+
+	if token == "" {
+		return requireLoopback(mux)
+	}
+
+	return requireBearer(token, mux)
+}
+
+// RunHTTP serves Handler on addr until ctx is cancelled. Without a token, addr
+// must be a loopback address.
+func (s *Server) RunHTTP(ctx context.Context, addr, token string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+
+	if token == "" && !isLoopback(host) {
+		return fmt.Errorf("refusing to serve %s without a bearer token: anyone who can reach it could commit to your repositories; set a token or listen on localhost", addr)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: s.Handler(token), ReadHeaderTimeout: 10 * time.Second}
+
+	log.Printf("Starting gic MCP server on http://%s (streamable HTTP at /mcp, SSE at /sse)...", listener.Addr())
+
+	if token == "" {
+		log.Println("No bearer token set: any local user or process can commit to your repositories")
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		// SSE streams stay open until their clients leave, so cut them off
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			_ = server.Close()
+		}
+
+		return nil
+	}
+}
+
+// requireBearer rejects requests that do not carry token as a bearer token.
+func requireBearer(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gic"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireLoopback rejects requests whose Host or Origin is not a loopback
+// host. A page on a DNS-rebound domain reaches a local server under its own
+// host name, and other pages send their origin.
+func requireLoopback(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if !isLoopback(host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !isLoopback(u.Hostname()) {
+				http.Error(w, "forbidden origin", http.StatusForbidden)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether host, a name or IP address without a port, only
+// reaches the local machine.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+
+	return ip != nil && ip.IsLoopback()
+}
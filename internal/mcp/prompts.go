@@ -0,0 +1,215 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gic/internal/commit"
+	"gic/internal/git"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// promptArguments are accepted by every prompt.
+var promptArguments = []*mcp.PromptArgument{
+	{Name: "user_context", Description: "Additional context about the changes"},
+	{Name: "repo_path", Description: "Path inside the git repository to use (default: the server's working directory)"},
+	{Name: "root", Description: "Name of a repository in the client's roots, as returned by list_repositories"},
+}
+
+// repositoryState is the repository context embedded in prompts.
+type repositoryState struct {
+	status, diff, log string
+	fileStats         []git.FileChange
+}
+
+// registerPrompts registers prompts that hand the current repository state to
+// the client's own model.
+func (s *Server) registerPrompts() {
+	s.addPrompt(
+		"commit",
+		"Write a commit message for the staged changes in the configured style, then commit them",
+		s.commitPrompt(""),
+	)
+
+	s.addPrompt(
+		"conventional_commit",
+		"Write a Conventional Commits message for the staged changes, then commit them",
+		s.commitPrompt(string(commit.StyleConventional)),
+	)
+
+	s.addPrompt(
+		"review_changes",
+		"Review the staged changes for bugs, risks and leftovers before they are committed",
+		func(_ context.Context, state repositoryState, args map[string]string) (string, error) {
+			if strings.TrimSpace(state.diff) == "" {
+				return "", fmt.Errorf("no staged changes to review")
+			}
+
+			return commit.ContextPrompt("review the staged changes", state.status, state.diff, state.log, state.fileStats, args["user_context"]) + `
+
+Point out bugs, security problems, missing tests, unclear names and leftover debugging code, citing the file and line of each.
+Order the findings by severity, and say so plainly when there is nothing worth changing.
+Do not write a commit message.`, nil
+		},
+	)
+
+	s.addPrompt(
+		"write_pr_description",
+		"Write a pull request description for the recent commits and any staged changes",
+		func(ctx context.Context, state repositoryState, args map[string]string) (string, error) {
+			branch, err := git.CompareBranch(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to compare with the base branch: %w", err)
+			}
+
+			prompt := commit.ContextPrompt("write a pull request description", state.status, state.diff, state.log, state.fileStats, args["user_context"])
+
+			scope := "the recent commits that belong to the current branch, ignoring older unrelated ones,"
+			if branch.Base != "" {
+				prompt += branchSection(branch)
+				scope = fmt.Sprintf("the commits on the branch since it forked from %s,", branch.Base)
+			}
+
+			return prompt + fmt.Sprintf(`
+
+Describe the work in %s together with any staged changes.
+Start with a one-line title, then explain what changed and why, and end with how it was tested.
+Use Markdown, and keep it short enough for a reviewer to read in a minute.`, scope), nil
+		},
+	)
+}
+
+// branchSection lists the commits and changed files a pull request from the
+// current branch would contain.
+func branchSection(branch git.BranchChanges) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "\n\nCommits on This Branch (since it forked from %s):\n```\n%s```", branch.Base, branch.Log)
+
+	if len(branch.Files) > 0 {
+		b.WriteString("\n\nFiles Changed on This Branch:\n```\n")
+
+		for _, stat := range branch.Files {
+			fmt.Fprintf(&b, "%s: %s\n", stat.Path, stat.Describe())
+		}
+
+		b.WriteString("```")
+	}
+
+	return b.String()
+}
+
+// commitPrompt renders the prompt generate_commit_message uses with the named
+// style, followed by how to commit the approved message.
+func (s *Server) commitPrompt(styleName string) func(context.Context, repositoryState, map[string]string) (string, error) {
+	return func(ctx context.Context, state repositoryState, args map[string]string) (string, error) {
+		if strings.TrimSpace(state.diff) == "" {
+			return "", fmt.Errorf("no staged changes to commit")
+		}
+
+		opts, err := s.messageOptions(ctx, styleName)
+		if err != nil {
+			return "", err
+		}
+
+		// The commit must go to the repository the prompt was rendered for
+		target := ""
+
+		for _, name := range []string{"repo_path", "root"} {
+			if value := args[name]; value != "" {
+				target += fmt.Sprintf(", %s %q", name, value)
+			}
+		}
+
+		return commit.MessagePrompt(state.status, state.diff, state.log, state.fileStats, args["user_context"], opts) +
+			fmt.Sprintf(`
+
+Once the user approves the message, commit it by calling the create_commit tool with it as message, stage "staged"%s.`, target), nil
+	}
+}
+
+// addPrompt registers a prompt whose text render builds from the state of
+// the repository selected by the prompt arguments.
+func (s *Server) addPrompt(name, description string, render func(ctx context.Context, state repositoryState, args map[string]string) (string, error)) {
+	s.server.AddPrompt(
+		&mcp.Prompt{
+			Name:        name,
+			Description: description,
+			Arguments:   promptArguments,
+		},
+		func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			args := req.Params.Arguments
+
+			ctx, err := s.repository(ctx, req.Session, args["repo_path"], args["root"])
+			if err != nil {
+				return nil, err
+			}
+
+			state, err := s.gatherState(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			text, err := render(ctx, state, args)
+			if err != nil {
+				return nil, err
+			}
+
+			return &mcp.GetPromptResult{
+				Description: description,
+				Messages: []*mcp.PromptMessage{
+					{Role: "user", Content: &mcp.TextContent{Text: text}},
+				},
+			}, nil
+		},
+	)
+}
+
+// gatherState gathers the status, staged diff and recent commits. The
+// diff is guarded against secrets and fitted to the prompt budget like the
+// tools do, without summaries since those would need a model.
+func (s *Server) gatherState(ctx context.Context) (repositoryState, error) {
+	var (
+		state repositoryState
+		err   error
+	)
+
+	if state.status, err = git.Status(ctx); err != nil {
+		return repositoryState{}, fmt.Errorf("git status failed: %w", err)
+	}
+
+	if state.fileStats, err = git.DiffStat(ctx); err != nil {
+		return repositoryState{}, fmt.Errorf("git diff stat failed: %w", err)
+	}
+
+	if state.diff, err = git.Diff(ctx); err != nil {
+		return repositoryState{}, fmt.Errorf("git diff failed: %w", err)
+	}
+
+	if state.log, err = git.Log(ctx); err != nil {
+		return repositoryState{}, fmt.Errorf("git log failed: %w", err)
+	}
+
+	if state.diff, _, err = s.guardSecrets(ctx, state.diff); err != nil {
+		return repositoryState{}, err
+	}
+
+	if strings.TrimSpace(state.diff) == "" {
+		return state, nil
+	}
+
+	opts, err := s.messageOptions(ctx, "")
+	if err != nil {
+		return repositoryState{}, err
+	}
+
+	opts.Summarize = false
+
+	if state.diff, _, err = commit.FitDiff(ctx, nil, state.status, state.diff, state.log, state.fileStats, opts); err != nil {
+		return repositoryState{}, err
+	}
+
+	return state, nil
+}
@@ -0,0 +1,192 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gic/internal/git"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Repository is a git repository found in one of the client's roots.
+type Repository struct {
+	Name string `json:"name" jsonschema:"Name to pass as root to the other tools and to use in git://{root}/... resource URIs"`
+	Path string `json:"path" jsonschema:"Absolute path of the working tree"`
+	Root string `json:"root" jsonschema:"URI of the client root the repository was found in"`
+}
+
+type ListRepositoriesInput struct{}
+
+type ListRepositoriesOutput struct {
+	Repositories []Repository `json:"repositories" jsonschema:"Git repositories in the client's roots"`
+}
+
+// handleListRepositories handles the list_repositories tool.
+func (s *Server) handleListRepositories(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	_ ListRepositoriesInput,
+) (*mcp.CallToolResult, ListRepositoriesOutput, error) {
+	repos, err := s.repositories(ctx, req.Session)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, ListRepositoriesOutput{}, err
+	}
+
+	return nil, ListRepositoriesOutput{Repositories: repos}, nil
+}
+
+// repositories discovers the git repositories in the client's roots. A root
+// inside a working tree stands for that repository; otherwise its immediate
+// subdirectories are searched, so that a folder of checkouts works too.
+func (s *Server) repositories(ctx context.Context, session *mcp.ServerSession) ([]Repository, error) {
+	if session == nil {
+		return nil, fmt.Errorf("client roots are not available")
+	}
+
+	result, err := session.ListRoots(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list client roots: %w", err)
+	}
+
+	backend, err := git.ParseBackend(s.config.GitBackend)
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []Repository
+
+	seen := make(map[string]bool)
+	names := make(map[string]int)
+
+	for _, root := range result.Roots {
+		dir, err := rootPath(root.URI)
+		if err != nil {
+			continue
+		}
+
+		for _, top := range worktrees(ctx, backend, dir) {
+			if seen[top] {
+				continue
+			}
+
+			seen[top] = true
+
+			// Names must tell repositories with the same directory name apart
+			name := filepath.Base(top)
+			names[name]++
+
+			if n := names[name]; n > 1 {
+				name += "-" + strconv.Itoa(n)
+			}
+
+			repos = append(repos, Repository{Name: name, Path: top, Root: root.URI})
+		}
+	}
+
+	return repos, nil
+}
+
+// findRepository returns the path of the repository called name in the
+// client's roots.
+func (s *Server) findRepository(ctx context.Context, session *mcp.ServerSession, name string) (string, error) {
+	repos, err := s.repositories(ctx, session)
+	if err != nil {
+		return "", err
+	}
+
+	for _, repo := range repos {
+		if repo.Name == name {
+			return repo.Path, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown repository %q (see list_repositories)", name)
+}
+
+// worktrees returns the top level of the working tree containing dir, or
+// else of each working tree directly below dir.
+func worktrees(ctx context.Context, backend git.Backend, dir string) []string {
+	if top, ok := worktreeTop(ctx, backend, dir); ok {
+		return []string{top}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var tops []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// Only directories with their own .git are worth a git command
+		child := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(child, ".git")); err != nil {
+			continue
+		}
+
+		if top, ok := worktreeTop(ctx, backend, child); ok {
+			tops = append(tops, top)
+		}
+	}
+
+	return tops
+}
+
+// worktreeTop returns the top level of the working tree containing dir.
+func worktreeTop(ctx context.Context, backend git.Backend, dir string) (string, bool) {
+	repo, err := git.OpenWorktree(ctx, backend, dir, git.Options{})
+	if err != nil {
+		return "", false
+	}
+
+	top, err := repo.TopLevel(ctx)
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Clean(top), true
+}
+
+// within reports whether path is dir or lies below it, following symlinks.
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(realPath(dir), realPath(path))
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// realPath makes p absolute and resolves its symlinks where possible.
+func realPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	}
+
+	return p
+}
+
+// rootPath converts a file:// root URI to a local path.
+func rootPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme != "file" || u.Path == "" {
+		return "", fmt.Errorf("unsupported root %q", uri)
+	}
+
+	return filepath.FromSlash(u.Path), nil
+}
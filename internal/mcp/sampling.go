@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gic/internal/client"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// samplingProvider asks the model of the connected MCP client through
+// sampling, so no credentials of gic's own are needed.
+type samplingProvider struct {
+	session   *mcp.ServerSession
+	model     string
+	maxTokens int64
+}
+
+// newSamplingProvider returns a provider for session, or nil when the client
+// does not support sampling.
+func newSamplingProvider(session *mcp.ServerSession, cfg client.ProviderConfig) client.Provider {
+	if session == nil {
+		return nil
+	}
+
+	params := session.InitializeParams()
+	if params == nil || params.Capabilities == nil || params.Capabilities.Sampling == nil {
+		return nil
+	}
+
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = client.DefaultMaxTokens
+	}
+
+	return &samplingProvider{session: session, model: cfg.Model, maxTokens: maxTokens}
+}
+
+// Ask sends a conversation to the client's model and returns the response
+// text.
+func (p *samplingProvider) Ask(ctx context.Context, messages []client.Message) (string, error) {
+	params := &mcp.CreateMessageParams{
+		MaxTokens: p.maxTokens,
+		// The client picks the model; a configured one is only a hint
+		ModelPreferences: &mcp.ModelPreferences{Hints: []*mcp.ModelHint{{Name: "claude"}}},
+	}
+
+	if p.model != "" {
+		params.ModelPreferences.Hints = append([]*mcp.ModelHint{{Name: p.model}}, params.ModelPreferences.Hints...)
+	}
+
+	for _, m := range messages {
+		role := mcp.Role("user")
+		if m.Assistant {
+			role = "assistant"
+		}
+
+		params.Messages = append(params.Messages, &mcp.SamplingMessage{Role: role, Content: &mcp.TextContent{Text: m.Text}})
+	}
+
+	result, err := p.session.CreateMessage(ctx, params)
+	if err != nil {
+		return "", fmt.Errorf("sampling request failed: %w", err)
+	}
+
+	text, ok := result.Content.(*mcp.TextContent)
+	if !ok {
+		return "", fmt.Errorf("sampling returned %T instead of text", result.Content)
+	}
+
+	return text.Text, nil
+}
+
+// AskStructured asks for the answer as a JSON object in the response text,
+// since sampling cannot force a tool call.
+func (p *samplingProvider) AskStructured(ctx context.Context, messages []client.Message, tool client.Tool) (json.RawMessage, error) {
+	schema, err := json.Marshal(map[string]any{
+		"type":       "object",
+		"properties": tool.Properties,
+		"required":   tool.Required,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages = append([]client.Message(nil), messages...)
+	messages[len(messages)-1].Text += fmt.Sprintf(
+		"\n\n%s\nReply with only a JSON object matching this JSON schema, without any other text:\n%s",
+		tool.Description, schema,
+	)
+
+	response, err := p.Ask(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	// Models often wrap JSON in a code fence despite the instructions
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start || !json.Valid([]byte(response[start:end+1])) {
+		return nil, fmt.Errorf("response did not include a %s answer", tool.Name)
+	}
+
+	return json.RawMessage(response[start : end+1]), nil
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"gic/internal/auth"
 	"gic/internal/client"
+	"gic/internal/commit"
+	"gic/internal/config"
 	"gic/internal/git"
+	"gic/internal/secrets"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -19,6 +24,10 @@ type Server struct {
 	server      *mcp.Server
 	accessToken string
 	tokenPath   string
+	config      *config.Config
+	// allowed are directories whose repositories repo_path may name besides
+	// the client's roots and the server's own repository.
+	allowed []string
 }
 
 // NewServer creates a new MCP server instance.
@@ -34,6 +43,7 @@ func NewServer(accessToken, tokenPath string) *Server {
 		server:      server,
 		accessToken: accessToken,
 		tokenPath:   tokenPath,
+		config:      &config.Config{},
 	}
 
 	// Register tools
@@ -42,35 +52,79 @@ func NewServer(accessToken, tokenPath string) *Server {
 	// Register resources
 	s.registerResources()
 
+	// Register prompts
+	s.registerPrompts()
+
 	return s
 }
 
+// SetConfig sets the user-level defaults used when a tool call leaves the
+// staging mode or style unset, along with the generation settings. The
+// .gic.yaml of the repository a call acts on is layered on top.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.config = cfg
+}
+
+// AllowPaths lets repo_path name repositories inside dirs, in addition to
+// those in the client's roots and the server's own repository.
+func (s *Server) AllowPaths(dirs ...string) {
+	s.allowed = append(s.allowed, dirs...)
+}
+
 // Run starts the MCP server with stdio transport.
 func (s *Server) Run(ctx context.Context) error {
 	log.Println("Starting gic MCP server...")
 	return s.server.Run(ctx, &mcp.StdioTransport{})
 }
 
+// Connect serves a single session over transport without blocking, e.g. an
+// in-memory transport in tests.
+func (s *Server) Connect(ctx context.Context, transport mcp.Transport) (*mcp.ServerSession, error) {
+	return s.server.Connect(ctx, transport, nil)
+}
+
 // Tool input/output types
 
 type GenerateCommitMessageInput struct {
-	UserContext string `json:"user_context,omitempty" jsonschema:"Additional context about the changes"`
+	UserContext string   `json:"user_context,omitempty" jsonschema:"Additional context about the changes"`
+	Stage       string   `json:"stage,omitempty" jsonschema:"Staging mode: auto (default), staged, tracked, all or paths"`
+	Paths       []string `json:"paths,omitempty" jsonschema:"Pathspecs to stage when stage is paths (implies paths mode)"`
+	Count       int      `json:"count,omitempty" jsonschema:"Number of distinct candidate messages to generate (1-5, default 1)"`
+	Style       string   `json:"style,omitempty" jsonschema:"Commit message style: default (follow recent commits) or conventional (Conventional Commits)"`
+	RepoPath    string   `json:"repo_path,omitempty" jsonschema:"Path inside the git repository to work on (default: the server's working directory)"`
+	Root        string   `json:"root,omitempty" jsonschema:"Name of a repository in the client's roots, as returned by list_repositories"`
 }
 
 type GenerateCommitMessageOutput struct {
-	CommitMessage string `json:"commit_message" jsonschema:"The generated commit message"`
+	CommitMessage   string      `json:"commit_message" jsonschema:"The generated commit message (the first candidate when several are requested)"`
+	Candidates      []string    `json:"candidates,omitempty" jsonschema:"All candidate messages when count is greater than 1"`
+	RedactedSecrets []string    `json:"redacted_secrets,omitempty" jsonschema:"Locations of potential secrets replaced with placeholders before the diff was sent"`
+	Usage           *TokenUsage `json:"usage,omitempty" jsonschema:"Tokens consumed by generation, when the provider reports them"`
+}
+
+// TokenUsage is the number of tokens a tool call consumed.
+type TokenUsage struct {
+	InputTokens  int64 `json:"input_tokens" jsonschema:"Tokens sent to the model"`
+	OutputTokens int64 `json:"output_tokens" jsonschema:"Tokens generated by the model"`
 }
 
 type CreateCommitInput struct {
-	UserContext string `json:"user_context,omitempty" jsonschema:"Additional context about the changes"`
-	Message     string `json:"message,omitempty" jsonschema:"Custom commit message (if not provided, one will be generated)"`
+	UserContext string   `json:"user_context,omitempty" jsonschema:"Additional context about the changes"`
+	Message     string   `json:"message,omitempty" jsonschema:"Custom commit message (if not provided, one will be generated)"`
+	Stage       string   `json:"stage,omitempty" jsonschema:"Staging mode: auto (default), staged, tracked, all or paths"`
+	Paths       []string `json:"paths,omitempty" jsonschema:"Pathspecs to stage when stage is paths (implies paths mode)"`
+	Style       string   `json:"style,omitempty" jsonschema:"Commit message style: default (follow recent commits) or conventional (Conventional Commits)"`
+	RepoPath    string   `json:"repo_path,omitempty" jsonschema:"Path inside the git repository to work on (default: the server's working directory)"`
+	Root        string   `json:"root,omitempty" jsonschema:"Name of a repository in the client's roots, as returned by list_repositories"`
 }
 
 type CreateCommitOutput struct {
-	CommitHash string `json:"commit_hash,omitempty" jsonschema:"The hash of the created commit"`
-	Message    string `json:"message" jsonschema:"The commit message used"`
-	Success    bool   `json:"success" jsonschema:"Whether the commit was successful"`
-	Error      string `json:"error,omitempty" jsonschema:"Error message if commit failed"`
+	CommitHash      string      `json:"commit_hash,omitempty" jsonschema:"The hash of the created commit"`
+	Message         string      `json:"message" jsonschema:"The commit message used"`
+	Success         bool        `json:"success" jsonschema:"Whether the commit was successful"`
+	Error           string      `json:"error,omitempty" jsonschema:"Error message if commit failed"`
+	RedactedSecrets []string    `json:"redacted_secrets,omitempty" jsonschema:"Locations of potential secrets replaced with placeholders before the diff was sent"`
+	Usage           *TokenUsage `json:"usage,omitempty" jsonschema:"Tokens consumed by generation, when the provider reports them"`
 }
 
 // registerTools registers all MCP tools.
@@ -81,8 +135,10 @@ func (s *Server) registerTools() {
 		&mcp.Tool{
 			Name: "generate_commit_message",
 			Description: "IMPORTANT: Use this tool whenever the user asks to generate a commit message, create a commit, or commit changes. " +
+				"Set count to get several distinct candidate messages to choose from. " +
 				"This tool analyzes git changes and generates an intelligent, contextual commit message using Claude AI. " +
-				"It automatically stages changes, reviews diffs, and creates a commit message that explains WHY changes were made, not just WHAT changed. " +
+				"It stages changes according to the stage mode (by default it keeps an existing index, or stages everything when nothing is staged), " +
+				"reviews the staged diff, and creates a commit message that explains WHY changes were made, not just WHAT changed. " +
 				"The generated message follows the repository's commit style by analyzing recent commits.",
 		},
 		s.handleGenerateCommitMessage,
@@ -94,93 +150,146 @@ func (s *Server) registerTools() {
 		&mcp.Tool{
 			Name: "create_commit",
 			Description: "IMPORTANT: Use this tool whenever the user asks to commit changes, create a commit, or save work to git. " +
-				"This tool stages all changes and creates a git commit with either a generated or provided message. " +
+				"This tool stages changes according to the stage mode and creates a git commit with either a generated or provided message. " +
+				"By default an existing index is committed as-is; when nothing is staged, all changes are staged. " +
 				"If no message is provided, it will automatically generate an intelligent commit message using Claude AI. " +
 				"Use this tool instead of manual git commands when the user wants to commit their work. " +
 				"Optionally provide user_context to guide the commit message generation (e.g., 'fixed bug in authentication' or 'added new feature').",
 		},
 		s.handleCreateCommit,
 	)
+
+	// Tool 3: List repositories
+	mcp.AddTool(
+		s.server,
+		&mcp.Tool{
+			Name: "list_repositories",
+			Description: "Lists the git repositories in the workspace roots the client has open. " +
+				"Pass a repository's name as root to the other tools to work on it, " +
+				"or read git://{root}/status, git://{root}/diff and git://{root}/recent-commits.",
+		},
+		s.handleListRepositories,
+	)
 }
 
-// registerResources registers all MCP resources.
+// registerResources registers all MCP resources. Each one is also available
+// as a template taking a repo_path query parameter, e.g.
+// git://status?repo_path=/path/to/repo, and per repository in the client's
+// roots, e.g. git://gic/status.
 func (s *Server) registerResources() {
 	// Resource 1: Git status
-	s.server.AddResource(
-		&mcp.Resource{
-			URI:         "git://status",
-			Name:        "Git Status",
-			Description: "Current git repository status",
-			MIMEType:    "text/plain",
-		},
-		func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
-			status, err := git.Status()
+	s.addResource(
+		"git://status",
+		"Git Status",
+		"Current git repository status",
+		func(ctx context.Context) (string, error) {
+			status, err := git.Status(ctx)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get git status: %w", err)
+				return "", fmt.Errorf("failed to get git status: %w", err)
 			}
 
-			return &mcp.ReadResourceResult{
-				Contents: []*mcp.ResourceContents{
-					{
-						URI:      "git://status",
-						MIMEType: "text/plain",
-						Text:     status,
-					},
-				},
-			}, nil
+			return status, nil
 		},
 	)
 
 	// Resource 2: Git diff
-	s.server.AddResource(
-		&mcp.Resource{
-			URI:         "git://diff",
-			Name:        "Git Diff",
-			Description: "Current git diff (staged and unstaged changes)",
-			MIMEType:    "text/plain",
-		},
-		func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
-			diff, err := git.Diff()
+	s.addResource(
+		"git://diff",
+		"Git Diff",
+		"Staged git diff (the changes the next commit will contain)",
+		func(ctx context.Context) (string, error) {
+			diff, err := git.Diff(ctx)
 			if err != nil {
-				return nil, fmt.Errorf("failed to get git diff: %w", err)
+				return "", fmt.Errorf("failed to get git diff: %w", err)
 			}
 
-			return &mcp.ReadResourceResult{
-				Contents: []*mcp.ResourceContents{
-					{
-						URI:      "git://diff",
-						MIMEType: "text/plain",
-						Text:     diff,
-					},
-				},
-			}, nil
+			diff, _, err = s.guardSecrets(ctx, diff)
+
+			return diff, err
 		},
 	)
 
 	// Resource 3: Recent commits
+	s.addResource(
+		"git://recent-commits",
+		"Recent Commits",
+		"Recent commit history (last 10 commits)",
+		func(ctx context.Context) (string, error) {
+			log, err := git.Log(ctx)
+			if err != nil {
+				return "", fmt.Errorf("failed to get git log: %w", err)
+			}
+
+			return log, nil
+		},
+	)
+}
+
+// addResource registers a plain-text resource at uri and templates for the
+// same resource in other repositories. read runs against the repository the
+// request selects.
+func (s *Server) addResource(uri, name, description string, read func(ctx context.Context) (string, error)) {
+	handler := func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+		u, err := url.Parse(req.Params.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource URI %q: %w", req.Params.URI, err)
+		}
+
+		// git://<root>/status names a root; git://status has no path
+		root := ""
+		if u.Path != "" {
+			root = u.Host
+		}
+
+		ctx, err = s.repository(ctx, req.Session, u.Query().Get("repo_path"), root)
+		if err != nil {
+			return nil, err
+		}
+
+		text, err := read(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      req.Params.URI,
+					MIMEType: "text/plain",
+					Text:     text,
+				},
+			},
+		}, nil
+	}
+
 	s.server.AddResource(
 		&mcp.Resource{
-			URI:         "git://recent-commits",
-			Name:        "Recent Commits",
-			Description: "Recent commit history (last 10 commits)",
+			URI:         uri,
+			Name:        name,
+			Description: description,
 			MIMEType:    "text/plain",
 		},
-		func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
-			log, err := git.Log()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get git log: %w", err)
-			}
+		handler,
+	)
 
-			return &mcp.ReadResourceResult{
-				Contents: []*mcp.ResourceContents{
-					{
-						URI:      "git://recent-commits",
-						MIMEType: "text/plain",
-						Text:     log,
-					},
-				},
-			}, nil
+	s.server.AddResourceTemplate(
+		&mcp.ResourceTemplate{
+			URITemplate: uri + "{?repo_path}",
+			Name:        name,
+			Description: description + " for the repository containing repo_path",
+			MIMEType:    "text/plain",
+		},
+		handler,
+	)
+
+	s.server.AddResourceTemplate(
+		&mcp.ResourceTemplate{
+			URITemplate: "git://{root}/" + strings.TrimPrefix(uri, "git://"),
+			Name:        name,
+			Description: description + " for a repository from list_repositories",
+			MIMEType:    "text/plain",
 		},
+		handler,
 	)
 }
 
@@ -190,13 +299,26 @@ func (s *Server) handleGenerateCommitMessage(
 	req *mcp.CallToolRequest,
 	input GenerateCommitMessageInput,
 ) (*mcp.CallToolResult, GenerateCommitMessageOutput, error) {
-	// Ensure token is valid
-	token, err := s.ensureValidToken()
+	ctx, err := s.repository(ctx, req.Session, input.RepoPath, input.Root)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+	}
+
+	opts, err := s.messageOptions(ctx, input.Style)
 	if err != nil {
 		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
 	}
 
-	s.accessToken = token
+	// Build the model backend, refreshing the token if it needs one
+	provider, err := s.provider(ctx, req.Session)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+	}
+
+	// Stage the selected changes
+	if err := s.stage(ctx, input.Stage, input.Paths); err != nil {
+		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+	}
 
 	// Gather git information
 	var (
@@ -212,7 +334,7 @@ func (s *Server) handleGenerateCommitMessage(
 	go func() {
 		defer wg.Done()
 
-		st, err := git.Status()
+		st, err := git.Status(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -229,7 +351,7 @@ func (s *Server) handleGenerateCommitMessage(
 	go func() {
 		defer wg.Done()
 
-		stats, err := git.DiffStat()
+		stats, err := git.DiffStat(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -246,7 +368,7 @@ func (s *Server) handleGenerateCommitMessage(
 	go func() {
 		defer wg.Done()
 
-		d, err := git.Diff()
+		d, err := git.Diff(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -263,7 +385,7 @@ func (s *Server) handleGenerateCommitMessage(
 	go func() {
 		defer wg.Done()
 
-		l, err := git.Log()
+		l, err := git.Log(ctx)
 		if err != nil {
 			mu.Lock()
 
@@ -289,13 +411,33 @@ func (s *Server) handleGenerateCommitMessage(
 			fmt.Errorf("no changes to commit")
 	}
 
+	// Keep secrets out of the prompt
+	diff, redacted, err := s.guardSecrets(ctx, diff)
+	if err != nil {
+		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+	}
+
+	if input.Count > 1 {
+		smartDiff, _, err := commit.FitDiff(ctx, provider, status, diff, log, fileStats, opts)
+		if err != nil {
+			return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+		}
+
+		candidates, err := commit.GenerateCandidates(ctx, provider, status, smartDiff, log, fileStats, input.UserContext, opts, input.Count)
+		if err != nil {
+			return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
+		}
+
+		return nil, GenerateCommitMessageOutput{CommitMessage: candidates[0], Candidates: candidates, RedactedSecrets: redacted, Usage: tokenUsage(provider)}, nil
+	}
+
 	// Generate commit message
-	commitMsg, err := generateCommitMessage(s.accessToken, status, diff, log, fileStats, input.UserContext)
+	commitMsg, err := generateCommitMessage(ctx, provider, progressReporter(ctx, req), status, diff, log, fileStats, input.UserContext, opts)
 	if err != nil {
 		return &mcp.CallToolResult{IsError: true}, GenerateCommitMessageOutput{}, err
 	}
 
-	return nil, GenerateCommitMessageOutput{CommitMessage: commitMsg}, nil
+	return nil, GenerateCommitMessageOutput{CommitMessage: commitMsg, RedactedSecrets: redacted, Usage: tokenUsage(provider)}, nil
 }
 
 // handleCreateCommit handles the create_commit tool.
@@ -304,34 +446,57 @@ func (s *Server) handleCreateCommit(
 	req *mcp.CallToolRequest,
 	input CreateCommitInput,
 ) (*mcp.CallToolResult, CreateCommitOutput, error) {
-	// Stage all changes
-	if err := git.Add("."); err != nil {
+	ctx, err := s.repository(ctx, req.Session, input.RepoPath, input.Root)
+	if err != nil {
+		return nil, CreateCommitOutput{
+			Success: false,
+			Error:   err.Error(),
+		}, nil
+	}
+
+	opts, err := s.messageOptions(ctx, input.Style)
+	if err != nil {
 		return nil, CreateCommitOutput{
 			Success: false,
-			Error:   fmt.Sprintf("failed to stage changes: %v", err),
+			Error:   err.Error(),
+		}, nil
+	}
+
+	// Stage the selected changes
+	if err := s.stage(ctx, input.Stage, input.Paths); err != nil {
+		return nil, CreateCommitOutput{
+			Success: false,
+			Error:   err.Error(),
 		}, nil
 	}
 
 	var (
 		commitMsg string
-		err       error
+		redacted  []string
+		usage     *TokenUsage
 	)
 
 	if input.Message != "" {
-		// Use provided message
+		// Use provided message, which must still follow the requested style
+		if err := opts.Style.Validate(input.Message); err != nil {
+			return nil, CreateCommitOutput{
+				Success: false,
+				Message: input.Message,
+				Error:   fmt.Sprintf("message does not follow the %s style: %v", opts.Style, err),
+			}, nil
+		}
+
 		commitMsg = input.Message
 	} else {
 		// Generate message
-		token, err := s.ensureValidToken()
+		provider, err := s.provider(ctx, req.Session)
 		if err != nil {
 			return nil, CreateCommitOutput{
 				Success: false,
-				Error:   fmt.Sprintf("failed to ensure valid token: %v", err),
+				Error:   err.Error(),
 			}, nil
 		}
 
-		s.accessToken = token
-
 		// Gather git information
 		var (
 			status, diff, log string
@@ -346,7 +511,7 @@ func (s *Server) handleCreateCommit(
 		go func() {
 			defer wg.Done()
 
-			st, err := git.Status()
+			st, err := git.Status(ctx)
 			if err != nil {
 				mu.Lock()
 
@@ -363,7 +528,7 @@ func (s *Server) handleCreateCommit(
 		go func() {
 			defer wg.Done()
 
-			stats, err := git.DiffStat()
+			stats, err := git.DiffStat(ctx)
 			if err != nil {
 				mu.Lock()
 
@@ -380,7 +545,7 @@ func (s *Server) handleCreateCommit(
 		go func() {
 			defer wg.Done()
 
-			d, err := git.Diff()
+			d, err := git.Diff(ctx)
 			if err != nil {
 				mu.Lock()
 
@@ -397,7 +562,7 @@ func (s *Server) handleCreateCommit(
 		go func() {
 			defer wg.Done()
 
-			l, err := git.Log()
+			l, err := git.Log(ctx)
 			if err != nil {
 				mu.Lock()
 
@@ -428,18 +593,49 @@ func (s *Server) handleCreateCommit(
 			}, nil
 		}
 
+		// Keep secrets out of the prompt
+		diff, redacted, err = s.guardSecrets(ctx, diff)
+		if err != nil {
+			return nil, CreateCommitOutput{
+				Success: false,
+				Error:   err.Error(),
+			}, nil
+		}
+
 		// Generate commit message
-		commitMsg, err = generateCommitMessage(s.accessToken, status, diff, log, fileStats, input.UserContext)
+		commitMsg, err = generateCommitMessage(ctx, provider, progressReporter(ctx, req), status, diff, log, fileStats, input.UserContext, opts)
 		if err != nil {
 			return nil, CreateCommitOutput{
 				Success: false,
 				Error:   fmt.Sprintf("failed to generate commit message: %v", err),
 			}, nil
 		}
+
+		usage = tokenUsage(provider)
+	}
+
+	// Let the user accept, edit or cancel the commit
+	confirmed, ok, err := s.confirmCommit(ctx, req.Session, commitMsg, opts.Style)
+	if err != nil {
+		return nil, CreateCommitOutput{
+			Success: false,
+			Message: commitMsg,
+			Error:   err.Error(),
+		}, nil
 	}
 
+	if !ok {
+		return nil, CreateCommitOutput{
+			Success: false,
+			Message: commitMsg,
+			Error:   "commit cancelled by the user; the changes are still staged",
+		}, nil
+	}
+
+	commitMsg = confirmed
+
 	// Create commit
-	if err = git.Commit(commitMsg); err != nil {
+	if err = git.Commit(ctx, commitMsg); err != nil {
 		return nil, CreateCommitOutput{
 			Success: false,
 			Message: commitMsg,
@@ -448,7 +644,7 @@ func (s *Server) handleCreateCommit(
 	}
 
 	// Get commit hash
-	output, _ := git.Log()
+	output, _ := git.Log(ctx)
 	lines := strings.Split(output, "\n")
 	commitHash := ""
 
@@ -460,156 +656,343 @@ func (s *Server) handleCreateCommit(
 	}
 
 	return nil, CreateCommitOutput{
-		Success:    true,
-		Message:    commitMsg,
-		CommitHash: commitHash,
+		Success:         true,
+		Message:         commitMsg,
+		CommitHash:      commitHash,
+		RedactedSecrets: redacted,
+		Usage:           usage,
 	}, nil
 }
 
-// ensureValidToken ensures the access token is valid, refreshing if needed.
-func (s *Server) ensureValidToken() (string, error) {
-	token, err := auth.Load(s.tokenPath)
+// repository returns ctx set up to act on the repository containing
+// repoPath, or the repository called root in the client's roots, accessed
+// through the configured backend. With neither the server's default
+// repository is kept. repoPath must lie in a client root, an allowed
+// directory or the server's own repository. The returned ctx also carries
+// the repository's configuration, see configFor.
+func (s *Server) repository(ctx context.Context, session *mcp.ServerSession, repoPath, root string) (context.Context, error) {
+	if repoPath != "" && root != "" {
+		return nil, fmt.Errorf("repo_path cannot be combined with root")
+	}
+
+	if repoPath == "" && root == "" {
+		// Outside a repository the git calls fail later with a clearer error
+		top, err := git.TopLevel(ctx)
+		if err != nil {
+			return withConfig(ctx, s.config), nil
+		}
+
+		cfg, err := s.repositoryConfig(top)
+		if err != nil {
+			return nil, err
+		}
+
+		return withConfig(ctx, cfg), nil
+	}
+
+	if root != "" {
+		path, err := s.findRepository(ctx, session, root)
+		if err != nil {
+			return nil, err
+		}
+
+		repoPath = path
+	}
+
+	backend, err := git.ParseBackend(s.config.GitBackend)
 	if err != nil {
-		return "", fmt.Errorf("failed to load token: %w", err)
+		return nil, err
 	}
 
-	token, err = auth.EnsureValid(token, s.tokenPath, auth.ClientID, auth.TokenURL)
+	repo, err := git.OpenWorktree(ctx, backend, repoPath, git.Options{})
 	if err != nil {
-		return "", fmt.Errorf("failed to ensure valid token: %w", err)
+		return nil, err
 	}
 
-	return token.AccessToken, nil
+	top, err := repo.TopLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Repositories named by root come from the client's roots already
+	if root == "" {
+		if err := s.checkAllowed(ctx, session, top); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := s.repositoryConfig(top)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reopen with the repository's settings, which may pick another backend
+	if backend, err = git.ParseBackend(cfg.GitBackend); err != nil {
+		return nil, err
+	}
+
+	repo, err = git.OpenWorktree(ctx, backend, repoPath, git.Options{Exclude: cfg.Exclude, Timeout: cfg.GitTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	return withConfig(git.WithRepository(ctx, repo), cfg), nil
 }
 
-// generateCommitMessage generates a commit message using Claude.
-func generateCommitMessage(accessToken, status, diff, log string, fileStats []git.FileChange, userInput string) (string, error) {
-	const (
-		maxPromptChars = 500000
-		promptOverhead = 2000
-	)
+// repositoryConfig layers the .gic.yaml of the repository at top over the
+// server's configuration.
+func (s *Server) repositoryConfig(top string) (*config.Config, error) {
+	path := filepath.Join(top, config.FileName)
 
-	totalSize := len(status) + len(diff) + len(log) + promptOverhead
+	layer, err := config.Read(path)
+	if err != nil {
+		return nil, err
+	}
 
-	var smartDiff string
-	if totalSize > maxPromptChars {
-		smartDiff = buildSmartDiff(fileStats, diff, maxPromptChars-len(status)-len(log)-promptOverhead)
-	} else {
-		smartDiff = diff
+	cfg := s.config.Clone()
+
+	for _, warning := range cfg.MergeRepository(layer, path) {
+		log.Printf("Warning: %s", warning)
 	}
 
-	hasSmartDiff := len(fileStats) > 0 && strings.Contains(smartDiff, "Changed Files Summary:")
+	return cfg, nil
+}
 
-	contextNote := ""
-	if hasSmartDiff {
-		contextNote = "\n(Note: Due to large changeset, detailed diffs shown for selected files only. Use summary above for full picture.)\n"
+// checkAllowed fails unless the repository at top lies in one of the client's
+// roots, an allowed directory or the server's own repository, so that tool
+// calls cannot reach every repository on the machine.
+func (s *Server) checkAllowed(ctx context.Context, session *mcp.ServerSession, top string) error {
+	dirs := append([]string(nil), s.allowed...)
+
+	if own, err := git.TopLevel(ctx); err == nil {
+		dirs = append(dirs, own)
 	}
 
-	userInputSection := ""
-	if userInput != "" {
-		userInputSection = fmt.Sprintf(`
+	// Clients without roots fail to list them, leaving the other directories
+	if session != nil {
+		if result, err := session.ListRoots(ctx, nil); err == nil {
+			for _, root := range result.Roots {
+				if dir, err := rootPath(root.URI); err == nil {
+					dirs = append(dirs, dir)
+				}
+			}
+		}
+	}
 
-User Input:
-`+"```"+`
-%s
-`+"```"+`
-`, userInput)
+	for _, dir := range dirs {
+		// A root inside a working tree stands for the whole repository
+		if within(top, dir) || within(dir, top) {
+			return nil
+		}
 	}
 
-	prompt := fmt.Sprintf(`Analyze the following git repository state and generate a concise commit message.
+	return fmt.Errorf("%s is outside the client's roots and the allowed paths", top)
+}
+
+type configKey struct{}
 
-Git Status:
-`+"```"+`
-%s
-`+"```"+`
+// withConfig returns a context carrying the configuration of the repository
+// a call acts on.
+func withConfig(ctx context.Context, cfg *config.Config) context.Context {
+	return context.WithValue(ctx, configKey{}, cfg)
+}
 
-Git Diff:
-`+"```"+`
-%s%s
-`+"```"+`
+// configFor returns the configuration attached by repository, or else the
+// server's own.
+func (s *Server) configFor(ctx context.Context) *config.Config {
+	if cfg, ok := ctx.Value(configKey{}).(*config.Config); ok {
+		return cfg
+	}
 
-Recent Commits (for style reference):
-`+"```"+`
-%s
-`+"```"+`%s
+	return s.config
+}
 
-IMPORTANT: Your entire response must be ONLY the commit message text itself.
-Do NOT include:
-- Any analysis or explanation
-- Prefixes like "Claude:", "Here's", "Based on"
-- Phrases like "I'll analyze" or "my suggested commit message is"
-- Signatures or attributions
+// stage applies the staging mode requested in a tool input, falling back to
+// the configured mode when neither a mode nor paths are given.
+func (s *Server) stage(ctx context.Context, modeName string, paths []string) error {
+	if modeName == "" && len(paths) == 0 {
+		modeName = s.configFor(ctx).Stage
+	}
 
-Write a commit message that:
-1. Summarizes the changes concisely (1-2 sentences)
-2. Focuses on WHY rather than WHAT
-3. Follows the style of recent commits shown above
+	mode, err := git.ParseStageMode(modeName)
+	if err != nil {
+		return err
+	}
 
-Start your response directly with the commit message text.`, status, smartDiff, contextNote, log, userInputSection)
+	if len(paths) > 0 {
+		if modeName != "" && mode != git.StagePaths {
+			return fmt.Errorf("paths cannot be combined with stage mode %q", mode)
+		}
 
-	return client.Ask(accessToken, prompt)
+		mode = git.StagePaths
+	}
+
+	if err := git.Stage(ctx, mode, paths); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	return nil
 }
 
-// buildSmartDiff creates an intelligent diff when the full diff is too large.
-func buildSmartDiff(fileStats []git.FileChange, fullDiff string, budget int) string {
-	if len(fileStats) == 0 {
-		return fullDiff
+// guardSecrets applies the configured secrets mode to a diff before it is
+// handed to a model, returning the locations of redacted secrets.
+func (s *Server) guardSecrets(ctx context.Context, diff string) (string, []string, error) {
+	mode, err := secrets.ParseMode(s.configFor(ctx).Secrets)
+	if err != nil {
+		return "", nil, err
 	}
 
-	var result strings.Builder
+	safeDiff, findings, err := secrets.Guard(diff, mode)
+	if err != nil {
+		return "", nil, err
+	}
 
-	result.WriteString("Changed Files Summary:\n")
+	var redacted []string
+	for _, f := range findings {
+		redacted = append(redacted, f.String())
+	}
 
-	for _, stat := range fileStats {
-		result.WriteString(fmt.Sprintf("  %s: +%d -%d lines\n", stat.Path, stat.Added, stat.Removed))
+	return safeDiff, redacted, nil
+}
+
+// messageOptions combines a tool's style argument with the configured
+// generation defaults.
+func (s *Server) messageOptions(ctx context.Context, styleName string) (commit.Options, error) {
+	cfg := s.configFor(ctx)
+
+	if styleName == "" {
+		styleName = cfg.Style
 	}
 
-	result.WriteString("\n")
+	style, err := commit.ParseStyle(styleName)
+	if err != nil {
+		return commit.Options{}, err
+	}
 
-	summarySize := result.Len()
+	return commit.Options{
+		Style:           style,
+		Language:        cfg.Language,
+		Instructions:    cfg.Prompt,
+		ContextWindow:   cfg.ProviderConfig().ContextWindow(),
+		MaxPromptTokens: cfg.MaxPromptTokens,
+		MaxPromptChars:  cfg.MaxPromptChars,
+		Summarize:       cfg.Summarize != nil && *cfg.Summarize,
+	}, nil
+}
 
-	// Sort files by size
-	sorted := make([]git.FileChange, len(fileStats))
-	copy(sorted, fileStats)
+// provider builds the model backend for a tool call. Unless a provider is
+// configured explicitly, a client that supports sampling answers through its
+// own model; otherwise the default backend is built, refreshing the OAuth
+// token first when it needs one.
+func (s *Server) provider(ctx context.Context, session *mcp.ServerSession) (client.Provider, error) {
+	cfg := s.configFor(ctx).ProviderConfig()
 
-	for i := 0; i < len(sorted)-1; i++ {
-		for j := i + 1; j < len(sorted); j++ {
-			if sorted[i].Added+sorted[i].Removed > sorted[j].Added+sorted[j].Removed {
-				sorted[i], sorted[j] = sorted[j], sorted[i]
-			}
+	if cfg.Name == "" {
+		if provider := newSamplingProvider(session, cfg); provider != nil {
+			return provider, nil
 		}
 	}
 
-	var (
-		selectedPaths []string
-		excludedPaths []string
-	)
+	// Clients may call tools concurrently, so the refreshed token stays local
+	accessToken := s.accessToken
 
-	usedBudget := summarySize
+	if cfg.IsAnthropic() {
+		method := auth.MethodAuto
+		if cfg.Name == client.ProviderAnthropicAPIKey {
+			method = auth.MethodAPIKey
+		}
 
-	for _, stat := range sorted {
-		estimatedSize := (stat.Added + stat.Removed) * 5
-		if usedBudget+estimatedSize > budget {
-			excludedPaths = append(excludedPaths, stat.Path)
-			continue
+		cred, err := auth.Resolve(filepath.Join(filepath.Dir(s.tokenPath), auth.CredentialsFile), cfg.APIKeyEnv, method)
+		if err != nil {
+			return nil, err
 		}
 
-		selectedPaths = append(selectedPaths, stat.Path)
-		usedBudget += estimatedSize
+		if cred.Method == auth.MethodAPIKey {
+			cfg.APIKey = cred.APIKey
+		} else {
+			token, err := s.ensureValidToken()
+			if err != nil {
+				return nil, err
+			}
+
+			accessToken = token
+		}
 	}
 
-	if len(selectedPaths) > 0 {
-		result.WriteString("Detailed Diffs (selected files):\n\n")
+	return client.NewProvider(cfg, accessToken)
+}
 
-		selectedDiff, err := git.DiffFiles(selectedPaths)
-		if err == nil {
-			result.WriteString(selectedDiff)
-		}
+// ensureValidToken ensures the access token is valid, refreshing if needed.
+func (s *Server) ensureValidToken() (string, error) {
+	token, err := auth.Load(s.tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load token: %w", err)
+	}
+
+	if token == nil {
+		return "", fmt.Errorf("authentication required: the client does not support sampling, so run 'gic auth login' or set %s", auth.DefaultAPIKeyEnv)
 	}
 
-	if len(excludedPaths) > 0 {
-		result.WriteString(fmt.Sprintf("\n[Note: Diffs excluded for %d large files: %s]\n",
-			len(excludedPaths), strings.Join(excludedPaths, ", ")))
+	token, err = auth.EnsureValid(token, s.tokenPath, auth.ClientID, auth.TokenURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to ensure valid token: %w", err)
+	}
+
+	return token.AccessToken, nil
+}
+
+// generateCommitMessage generates a commit message using Claude, streaming
+// the draft to onText when it is set.
+func generateCommitMessage(ctx context.Context, provider client.Provider, onText func(string), status, diff, log string, fileStats []git.FileChange, userInput string, opts commit.Options) (string, error) {
+	if onText != nil {
+		ctx = commit.WithSummaryProgress(ctx, func(done, total int) {
+			onText(fmt.Sprintf("Summarizing large files (%d/%d)", done, total))
+		})
+	}
+
+	smartDiff, _, err := commit.FitDiff(ctx, provider, status, diff, log, fileStats, opts)
+	if err != nil {
+		return "", err
 	}
 
-	return result.String()
+	return commit.StreamMessage(ctx, provider, onText, status, smartDiff, log, fileStats, userInput, opts)
+}
+
+// tokenUsage returns the tokens provider has consumed, or nil when it does
+// not track usage.
+func tokenUsage(provider client.Provider) *TokenUsage {
+	reporter, ok := provider.(client.UsageReporter)
+	if !ok {
+		return nil
+	}
+
+	usage := reporter.Usage()
+
+	return &TokenUsage{InputTokens: usage.InputTokens, OutputTokens: usage.OutputTokens}
+}
+
+// progressReporter forwards a streamed draft to the client as progress
+// notifications. It returns nil when the request carries no progress token.
+func progressReporter(ctx context.Context, req *mcp.CallToolRequest) func(string) {
+	if req == nil || req.Session == nil || req.Params == nil {
+		return nil
+	}
+
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return nil
+	}
+
+	var progress float64
+
+	return func(text string) {
+		// Progress must increase even when a re-ask restarts the draft
+		progress++
+
+		_ = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+			ProgressToken: token,
+			Message:       text,
+			Progress:      progress,
+		})
+	}
 }
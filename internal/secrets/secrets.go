@@ -0,0 +1,383 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mode selects what happens when a diff contains secrets.
+type Mode string
+
+const (
+	// ModeRedact replaces secrets with placeholders before the diff is sent.
+	ModeRedact Mode = "redact"
+	// ModeBlock refuses to send a diff that contains secrets.
+	ModeBlock Mode = "block"
+	// ModeOff sends diffs verbatim.
+	ModeOff Mode = "off"
+)
+
+// ParseMode validates a mode name. An empty name means ModeRedact.
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case "", ModeRedact:
+		return ModeRedact, nil
+	case ModeBlock, ModeOff:
+		return Mode(name), nil
+	default:
+		return "", fmt.Errorf("unknown secrets mode %q (expected redact, block or off)", name)
+	}
+}
+
+// strictness orders the modes by how much they keep back.
+var strictness = map[Mode]int{ModeOff: 0, ModeRedact: 1, ModeBlock: 2}
+
+// Weaker reports whether m lets more through than other, e.g. ModeOff
+// compared to ModeRedact.
+func (m Mode) Weaker(other Mode) bool {
+	return strictness[m] < strictness[other]
+}
+
+// Finding is a secret detected in a diff.
+type Finding struct {
+	// File is the path of the changed file.
+	File string
+	// Line is the line number in the new file, or in the old file for
+	// removed lines.
+	Line int
+	// Removed marks secrets on removed lines.
+	Removed bool
+	// Rule names the detector that matched.
+	Rule string
+}
+
+func (f Finding) String() string {
+	location := f.File + ":" + strconv.Itoa(f.Line)
+	if f.Removed {
+		location += " (removed line)"
+	}
+
+	return fmt.Sprintf("%s  %s", location, f.Rule)
+}
+
+// rule detects one kind of secret. When group is set, only that submatch is
+// the secret; otherwise the whole match is. check, if set, filters out
+// matches that are not secrets after all.
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+	group   int
+	check   func(string) bool
+}
+
+var rules = []rule{
+	{name: "aws-access-key-id", pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA|A3T[A-Z0-9])[A-Z0-9]{16}\b`)},
+	{name: "aws-secret-access-key", pattern: regexp.MustCompile(`(?i)aws.{0,20}?(?:secret|key).{0,20}?['"=:\s]([A-Za-z0-9/+=]{40})\b`), group: 1},
+	{name: "github-token", pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{name: "slack-token", pattern: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}\b`)},
+	{name: "api-key", pattern: regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}\b`)},
+	{name: "google-api-key", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "stripe-key", pattern: regexp.MustCompile(`\b(?:sk|rk)_(?:live|test)_[0-9A-Za-z]{16,}\b`)},
+	{name: "jwt", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\b`)},
+	{name: "url-credentials", pattern: regexp.MustCompile(`[a-z][a-z0-9+.-]*://[^/\s:@]+:([^/\s@]{3,})@`), group: 1},
+	{
+		name:    "secret-assignment",
+		pattern: regexp.MustCompile(`(?i)\b[A-Z0-9_.-]*(?:SECRET|TOKEN|PASSWORD|PASSWD|PWD|API_?KEY|PRIVATE_?KEY|ACCESS_?KEY|CREDENTIALS?)[A-Z0-9_]*['"]?\s*[:=]\s*['"]?([^'"\s,;#]{8,})`),
+		group:   1,
+		check:   isLiteralValue,
+	},
+}
+
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	entropyToken    = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+	hunkHeader      = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+	dottedIdent     = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)+$`)
+)
+
+// minEntropy is the Shannon entropy, in bits per character, above which a
+// long mixed-case alphanumeric token is treated as a secret. Hex digests
+// top out at 4 and stay below it.
+const minEntropy = 4.5
+
+// Placeholder returns the text that replaces a secret found by rule.
+func Placeholder(rule string) string {
+	return "[REDACTED " + rule + "]"
+}
+
+// Scan reports the secrets in a unified diff without changing it.
+func Scan(diff string) []Finding {
+	_, findings := process(diff)
+	return findings
+}
+
+// Redact replaces every secret in a unified diff with a placeholder and
+// reports what was replaced.
+func Redact(diff string) (string, []Finding) {
+	return process(diff)
+}
+
+// BlockedError is returned by Guard in block mode when a diff contains secrets.
+type BlockedError struct {
+	Findings []Finding
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("diff contains %d potential secrets; remove them or use secrets mode \"redact\":\n%s", len(e.Findings), Report(e.Findings))
+}
+
+// Guard applies mode to a diff about to leave the machine. It returns the diff
+// to send and the secrets found in it; in block mode any finding yields a
+// *BlockedError instead.
+func Guard(diff string, mode Mode) (string, []Finding, error) {
+	if mode == ModeOff {
+		return diff, nil, nil
+	}
+
+	redacted, findings := Redact(diff)
+	if len(findings) == 0 {
+		return diff, nil, nil
+	}
+
+	if mode == ModeBlock {
+		return "", findings, &BlockedError{Findings: findings}
+	}
+
+	return redacted, findings, nil
+}
+
+// Report renders findings one per line.
+func Report(findings []Finding) string {
+	lines := make([]string, len(findings))
+	for i, f := range findings {
+		lines[i] = f.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// process walks the diff line by line, tracking the current file and line
+// numbers, and redacts secrets on added, removed and context lines alike:
+// all of them are sent to the model.
+func process(diff string) (string, []Finding) {
+	var (
+		findings         []Finding
+		file             string
+		oldLine, newLine int
+		inHunk           bool
+		inKey            bool
+	)
+
+	lines := strings.Split(diff, "\n")
+
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			file = diffGitPath(line)
+			inHunk, inKey = false, false
+
+			continue
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				file = strings.TrimPrefix(path, "b/")
+			}
+
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if m := hunkHeader.FindStringSubmatch(line); m != nil {
+				oldLine, _ = strconv.Atoi(m[1])
+				newLine, _ = strconv.Atoi(m[2])
+				inHunk = true
+			}
+
+			continue
+		case !inHunk || line == "" || (line[0] != '+' && line[0] != '-' && line[0] != ' '):
+			continue
+		}
+
+		prefix, content := line[:1], line[1:]
+
+		lineNo, removed := newLine, prefix == "-"
+		if removed {
+			lineNo = oldLine
+		}
+
+		switch prefix {
+		case "+":
+			newLine++
+		case "-":
+			oldLine++
+		default:
+			oldLine++
+			newLine++
+		}
+
+		at := func(rule string) Finding {
+			return Finding{File: file, Line: lineNo, Removed: removed, Rule: rule}
+		}
+
+		// Private key blocks are redacted line by line and reported once
+		if inKey || privateKeyBegin.MatchString(content) {
+			if !inKey {
+				findings = append(findings, at("private-key"))
+			}
+
+			inKey = !privateKeyEnd.MatchString(content)
+			lines[i] = prefix + Placeholder("private-key")
+
+			continue
+		}
+
+		redacted, rulesHit := redactLine(content)
+		for _, name := range rulesHit {
+			findings = append(findings, at(name))
+		}
+
+		lines[i] = prefix + redacted
+	}
+
+	return strings.Join(lines, "\n"), findings
+}
+
+// redactLine replaces the secrets in one line and returns the rules that
+// matched, once each.
+func redactLine(content string) (string, []string) {
+	var hit []string
+
+	for _, r := range rules {
+		matched := false
+
+		content = replaceMatches(r.pattern, content, r.group, func(secret string) (string, bool) {
+			// Already redacted by an earlier, more specific rule
+			if strings.HasPrefix(secret, "[REDACTED") || (r.check != nil && !r.check(secret)) {
+				return secret, false
+			}
+
+			matched = true
+
+			return Placeholder(r.name), true
+		})
+
+		if matched {
+			hit = append(hit, r.name)
+		}
+	}
+
+	matched := false
+
+	content = replaceMatches(entropyToken, content, 0, func(token string) (string, bool) {
+		if !looksRandom(token) {
+			return token, false
+		}
+
+		matched = true
+
+		return Placeholder("high-entropy-string"), true
+	})
+
+	if matched {
+		hit = append(hit, "high-entropy-string")
+	}
+
+	return content, hit
+}
+
+// replaceMatches rewrites submatch group of every match of pattern with the
+// result of replace.
+func replaceMatches(pattern *regexp.Regexp, s string, group int, replace func(string) (string, bool)) string {
+	matches := pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+
+	last := 0
+
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 {
+			continue
+		}
+
+		replacement, ok := replace(s[start:end])
+		if !ok {
+			continue
+		}
+
+		b.WriteString(s[last:start])
+		b.WriteString(replacement)
+
+		last = end
+	}
+
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// isLiteralValue reports whether an assigned value is a literal rather than
+// code or a template: calls, field accesses and variable references such as
+// cfg.Password, getToken() or ${TOKEN} are not secrets.
+func isLiteralValue(value string) bool {
+	switch {
+	case strings.ContainsAny(value, "()$<>{}"):
+		return false
+	case dottedIdent.MatchString(value):
+		return false
+	default:
+		return true
+	}
+}
+
+// looksRandom reports whether a token mixes upper case, lower case and
+// digits with high entropy, as generated keys do.
+func looksRandom(token string) bool {
+	var upper, lower, digit bool
+
+	for _, r := range token {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+
+	return upper && lower && digit && entropy(token) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	var h float64
+
+	n := float64(len(s))
+
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+
+	return h
+}
+
+// diffGitPath extracts the new path from a "diff --git a/x b/x" line.
+func diffGitPath(line string) string {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.LastIndex(rest, " b/"); i >= 0 {
+		return rest[i+3:]
+	}
+
+	return rest
+}
@@ -0,0 +1,170 @@
+package semantic
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+func init() {
+	Register(goParser{}, ".go")
+}
+
+// goParser reads Go declarations with go/parser.
+type goParser struct{}
+
+func (goParser) Parse(src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	text := func(node ast.Node) string {
+		return string(src[fset.Position(node.Pos()).Offset:fset.Position(node.End()).Offset])
+	}
+
+	var symbols []Symbol
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			symbols = append(symbols, funcSymbol(fset, d, text(d)))
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				symbols = append(symbols, specSymbols(fset, d.Tok, spec, text(spec))...)
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// funcSymbol describes a function or method.
+func funcSymbol(fset *token.FileSet, d *ast.FuncDecl, body string) Symbol {
+	sym := Symbol{
+		Name:     d.Name.Name,
+		Kind:     "func",
+		Body:     body,
+		Exported: d.Name.IsExported(),
+	}
+
+	if d.Recv != nil && len(d.Recv.List) > 0 {
+		recv := receiverType(d.Recv.List[0].Type)
+
+		sym.Name = recv + "." + d.Name.Name
+		sym.Kind = "method"
+		sym.Exported = sym.Exported && ast.IsExported(recv)
+	}
+
+	// The signature is the declaration without its body
+	signature := *d
+	signature.Body = nil
+	signature.Doc = nil
+	sym.Signature = render(fset, &signature)
+
+	return sym
+}
+
+// specSymbols describes the types, constants or variables of a spec.
+func specSymbols(fset *token.FileSet, tok token.Token, spec ast.Spec, body string) []Symbol {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return []Symbol{{
+			Name:      s.Name.Name,
+			Kind:      "type",
+			Signature: "type " + s.Name.Name + typeParams(fset, s) + " " + typeKind(fset, s.Type),
+			Body:      body,
+			Exported:  s.Name.IsExported(),
+		}}
+	case *ast.ValueSpec:
+		var symbols []Symbol
+
+		for _, name := range s.Names {
+			if name.Name == "_" {
+				continue
+			}
+
+			// Values are left out; they may hold literals that belong in the diff only
+			signature := tok.String() + " " + name.Name
+			if s.Type != nil {
+				signature += " " + render(fset, s.Type)
+			}
+
+			symbols = append(symbols, Symbol{
+				Name:      name.Name,
+				Kind:      tok.String(),
+				Signature: signature,
+				Body:      body,
+				Exported:  name.IsExported(),
+			})
+		}
+
+		return symbols
+	}
+
+	return nil
+}
+
+// receiverType returns the bare type name of a method receiver.
+func receiverType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		return receiverType(t.X)
+	case *ast.IndexListExpr:
+		return receiverType(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+
+	return ""
+}
+
+// typeParams renders the type parameters of a generic type.
+func typeParams(fset *token.FileSet, s *ast.TypeSpec) string {
+	if s.TypeParams == nil {
+		return ""
+	}
+
+	params := make([]string, len(s.TypeParams.List))
+
+	for i, field := range s.TypeParams.List {
+		names := make([]string, len(field.Names))
+		for j, name := range field.Names {
+			names[j] = name.Name
+		}
+
+		params[i] = strings.Join(names, ", ") + " " + render(fset, field.Type)
+	}
+
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// typeKind summarises a type definition: struct and interface bodies are
+// left out, other types are shown in full.
+func typeKind(fset *token.FileSet, expr ast.Expr) string {
+	switch expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+
+	return render(fset, expr)
+}
+
+// render prints node on a single line.
+func render(fset *token.FileSet, node any) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
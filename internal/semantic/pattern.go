@@ -0,0 +1,231 @@
+package semantic
+
+import (
+	"regexp"
+	"strings"
+)
+
+func init() {
+	Register(pythonParser, ".py")
+	Register(scriptParser, ".js", ".jsx", ".mjs", ".cjs", ".ts", ".tsx", ".mts", ".cts")
+}
+
+// declPattern matches a top-level declaration line. The name group holds
+// the symbol name.
+type declPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// patternParser finds declarations that start at the beginning of a line,
+// which covers the top-level definitions of indentation- and brace-based
+// languages without a full grammar. Each symbol's body runs to the next
+// declaration.
+type patternParser struct {
+	patterns []declPattern
+	// exported decides whether a symbol is part of the public API.
+	exported func(name, line string) bool
+	// colonBody is set for languages whose bodies start after a colon, which
+	// may be followed by a one-line body on the declaration line.
+	colonBody bool
+}
+
+var pythonParser = patternParser{
+	patterns: []declPattern{
+		{kind: "func", re: regexp.MustCompile(`^(?:async\s+)?def\s+(?P<name>\w+)`)},
+		{kind: "class", re: regexp.MustCompile(`^class\s+(?P<name>\w+)`)},
+	},
+	exported: func(name, _ string) bool {
+		return !strings.HasPrefix(name, "_")
+	},
+	colonBody: true,
+}
+
+var scriptParser = patternParser{
+	patterns: []declPattern{
+		{kind: "func", re: regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:async\s+)?function\*?\s+(?P<name>[\w$]+)`)},
+		{kind: "class", re: regexp.MustCompile(`^(?:export\s+(?:default\s+)?)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`)},
+		{kind: "type", re: regexp.MustCompile(`^(?:export\s+)?(?:declare\s+)?(?:interface|type|enum)\s+(?P<name>[\w$]+)`)},
+		{kind: "var", re: regexp.MustCompile(`^(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)`)},
+	},
+	exported: func(_, line string) bool {
+		return strings.HasPrefix(line, "export")
+	},
+}
+
+func (p patternParser) Parse(src []byte) ([]Symbol, error) {
+	lines := strings.SplitAfter(string(src), "\n")
+
+	var (
+		symbols []Symbol
+		body    strings.Builder
+	)
+
+	flush := func() {
+		if len(symbols) > 0 {
+			symbols[len(symbols)-1].Body = body.String()
+		}
+
+		body.Reset()
+	}
+
+	for _, line := range lines {
+		if sym, ok := p.match(line); ok {
+			flush()
+
+			symbols = append(symbols, sym)
+		}
+
+		if len(symbols) > 0 {
+			body.WriteString(line)
+		}
+	}
+
+	flush()
+
+	return symbols, nil
+}
+
+// match reports whether line starts a declaration.
+func (p patternParser) match(line string) (Symbol, bool) {
+	for _, pattern := range p.patterns {
+		m := pattern.re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		name := m[pattern.re.SubexpIndex("name")]
+
+		return Symbol{
+			Name:      name,
+			Kind:      pattern.kind,
+			Signature: signatureLine(pattern.kind, line, p.colonBody),
+			Exported:  p.exported(name, line),
+		}, true
+	}
+
+	return Symbol{}, false
+}
+
+// signatureLine trims a declaration line down to its signature, leaving
+// out bodies, assigned values and parameter defaults. Unlike the diff, the
+// signature may come from an unchanged line the secrets guard never saw, so
+// no literal from the source may survive.
+func signatureLine(kind, line string, colonBody bool) string {
+	line = stripDefaults(strings.TrimSpace(line))
+
+	if colonBody {
+		return strings.TrimSpace(cutBody(line))
+	}
+
+	cut := strings.LastIndex(line, "{")
+	if kind == "var" || kind == "type" {
+		if i := strings.IndexAny(line, "={"); i >= 0 {
+			cut = i
+		}
+	}
+
+	if cut > 0 {
+		line = line[:cut]
+	}
+
+	return strings.TrimSuffix(strings.TrimSpace(line), ":")
+}
+
+// stripDefaults removes the default values of parameters, such as ="hunter2"
+// in def connect(password="hunter2"), keeping the parameter names.
+func stripDefaults(line string) string {
+	var (
+		b         strings.Builder
+		depth     int
+		skipDepth int
+		quote     byte
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		skipping := skipDepth > 0
+
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(line) {
+				// Keep the escaped character with its backslash
+				if !skipping {
+					b.WriteByte(c)
+				}
+
+				i++
+				c = line[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+
+			if depth < skipDepth {
+				skipDepth, skipping = 0, false
+			}
+		case c == ',' && depth == skipDepth:
+			skipDepth, skipping = 0, false
+		case c == '=' && depth > 0 && !skipping && isAssignment(line, i):
+			skipDepth, skipping = depth, true
+
+			trimmed := strings.TrimRight(b.String(), " ")
+			b.Reset()
+			b.WriteString(trimmed)
+		}
+
+		if !skipping {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// cutBody cuts line at the colon that starts the body, the first one outside
+// brackets and strings, so that a body on the same line is left out, as in
+// def token(): return "secret".
+func cutBody(line string) string {
+	var (
+		depth int
+		quote byte
+	)
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ':' && depth == 0:
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// isAssignment reports whether the = at line[i] assigns, rather than being
+// part of an operator such as ==, => or <=.
+func isAssignment(line string, i int) bool {
+	if i > 0 && strings.IndexByte("=!<>", line[i-1]) >= 0 {
+		return false
+	}
+
+	return i+1 >= len(line) || (line[i+1] != '=' && line[i+1] != '>')
+}
@@ -0,0 +1,239 @@
+// Package semantic lists the declarations a change adds, removes or modifies,
+// giving the model a compact, high-signal view of large diffs.
+package semantic
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned for files no registered parser understands.
+var ErrUnsupported = errors.New("no parser for this file type")
+
+// Symbol is a top-level declaration in a source file.
+type Symbol struct {
+	// Name identifies the symbol within its file, e.g. "Server.Run" for a
+	// method.
+	Name string
+	// Kind is the kind of declaration, e.g. "func", "method", "type" or
+	// "class".
+	Kind string
+	// Signature is the declaration without its body.
+	Signature string
+	// Body is the full text of the declaration, compared to detect changes.
+	Body string
+	// Exported reports whether the symbol is part of the public API.
+	Exported bool
+}
+
+// Parser extracts the top-level symbols of a source file.
+type Parser interface {
+	Parse(src []byte) ([]Symbol, error)
+}
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[string]Parser{}
+)
+
+// Register makes p parse files with the given extensions, e.g. ".go".
+// Later registrations replace earlier ones.
+func Register(p Parser, extensions ...string) {
+	parsersMu.Lock()
+	defer parsersMu.Unlock()
+
+	for _, ext := range extensions {
+		parsers[strings.ToLower(ext)] = p
+	}
+}
+
+// Supported reports whether a parser is registered for path.
+func Supported(path string) bool {
+	_, ok := parserFor(path)
+
+	return ok
+}
+
+func parserFor(path string) (Parser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+
+	p, ok := parsers[strings.ToLower(filepath.Ext(path))]
+
+	return p, ok
+}
+
+// ChangeKind is how a symbol changed.
+type ChangeKind string
+
+const (
+	Added    ChangeKind = "added"
+	Removed  ChangeKind = "removed"
+	Modified ChangeKind = "modified"
+)
+
+// Change is a symbol that was added, removed or modified.
+type Change struct {
+	Kind ChangeKind
+	// Symbol is the new version, or the old one for removed symbols.
+	Symbol Symbol
+	// SignatureChanged is set for modified symbols whose signature differs.
+	SignatureChanged bool
+}
+
+// APIChange reports whether the change affects the public API: an exported
+// symbol was added, removed or got a new signature.
+func (c Change) APIChange() bool {
+	return c.Symbol.Exported && (c.Kind != Modified || c.SignatureChanged)
+}
+
+// File is the list of changed symbols in one file.
+type File struct {
+	Path    string
+	Changes []Change
+}
+
+// APIChange reports whether any change in the file affects the public API.
+func (f File) APIChange() bool {
+	for _, c := range f.Changes {
+		if c.APIChange() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compare parses the old and new version of the file at path and returns
+// its changed symbols, ordered by name. An empty before or after means the
+// file was added or deleted.
+func Compare(path, before, after string) ([]Change, error) {
+	parser, ok := parserFor(path)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	oldSymbols, err := parseAll(parser, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse old version of %s: %w", path, err)
+	}
+
+	newSymbols, err := parseAll(parser, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse new version of %s: %w", path, err)
+	}
+
+	var changes []Change
+
+	for name, sym := range newSymbols {
+		old, existed := oldSymbols[name]
+
+		switch {
+		case !existed:
+			changes = append(changes, Change{Kind: Added, Symbol: sym})
+		case old.Body != sym.Body:
+			changes = append(changes, Change{Kind: Modified, Symbol: sym, SignatureChanged: old.Signature != sym.Signature})
+		}
+	}
+
+	for name, sym := range oldSymbols {
+		if _, exists := newSymbols[name]; !exists {
+			changes = append(changes, Change{Kind: Removed, Symbol: sym})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Symbol.Name < changes[j].Symbol.Name
+	})
+
+	return changes, nil
+}
+
+// parseAll parses src into symbols keyed by name. Repeated names, such as
+// several init functions, are numbered.
+func parseAll(parser Parser, src string) (map[string]Symbol, error) {
+	symbols := make(map[string]Symbol)
+	if strings.TrimSpace(src) == "" {
+		return symbols, nil
+	}
+
+	parsed, err := parser.Parse([]byte(src))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sym := range parsed {
+		name := sym.Name
+		for n := 2; ; n++ {
+			if _, taken := symbols[name]; !taken {
+				break
+			}
+
+			name = fmt.Sprintf("%s#%d", sym.Name, n)
+		}
+
+		sym.Name = name
+		symbols[name] = sym
+	}
+
+	return symbols, nil
+}
+
+// changeMarks prefixes rendered changes by kind.
+var changeMarks = map[ChangeKind]string{Added: "+", Removed: "-", Modified: "~"}
+
+// Render lists the changed symbols of files for a prompt, at most limit
+// lines of changes in total. API-affecting changes are marked and listed
+// first within each file.
+func Render(files []File, limit int) string {
+	var b strings.Builder
+
+	b.WriteString("Changed Symbols (+ added, - removed, ~ modified; [API] marks public API changes):\n")
+
+	shown, total := 0, 0
+
+	for _, f := range files {
+		total += len(f.Changes)
+
+		if len(f.Changes) == 0 || shown >= limit {
+			continue
+		}
+
+		changes := make([]Change, len(f.Changes))
+		copy(changes, f.Changes)
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].APIChange() && !changes[j].APIChange()
+		})
+
+		b.WriteString(fmt.Sprintf("  %s\n", f.Path))
+
+		for _, c := range changes {
+			if shown >= limit {
+				break
+			}
+
+			line := fmt.Sprintf("    %s %s", changeMarks[c.Kind], c.Symbol.Signature)
+			if c.SignatureChanged {
+				line += " (signature changed)"
+			}
+
+			if c.APIChange() {
+				line += " [API]"
+			}
+
+			b.WriteString(line + "\n")
+
+			shown++
+		}
+	}
+
+	if total > shown {
+		b.WriteString(fmt.Sprintf("  [... %d more changed symbols not shown]\n", total-shown))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package semantic_test
+
+import (
+	"testing"
+
+	"gic/internal/semantic"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCompareGo verifies that Go declarations are diffed by name, with
+// signature and API changes detected
+func TestCompareGo(t *testing.T) {
+	before := `package server
+
+type Server struct{ addr string }
+
+func (s *Server) Run() error { return nil }
+
+func helper() {}
+
+func Remove() {}
+`
+
+	after := `package server
+
+type Server struct{ addr string }
+
+func (s *Server) Run(ctx context.Context) error { return nil }
+
+func helper() { println("changed") }
+
+func New[T any](addr string) *Server { return nil }
+
+const Version = "2"
+`
+
+	changes, err := semantic.Compare("server.go", before, after)
+	require.NoError(t, err)
+
+	byName := make(map[string]semantic.Change)
+	for _, c := range changes {
+		byName[c.Symbol.Name] = c
+	}
+
+	require.Len(t, byName, 5)
+
+	assert.Equal(t, semantic.Modified, byName["Server.Run"].Kind)
+	assert.True(t, byName["Server.Run"].SignatureChanged)
+	assert.True(t, byName["Server.Run"].APIChange())
+	assert.Equal(t, "func (s *Server) Run(ctx context.Context) error", byName["Server.Run"].Symbol.Signature)
+
+	assert.Equal(t, semantic.Modified, byName["helper"].Kind)
+	assert.False(t, byName["helper"].APIChange(), "unexported")
+
+	assert.Equal(t, semantic.Added, byName["New"].Kind)
+	assert.Equal(t, "func New[T any](addr string) *Server", byName["New"].Symbol.Signature)
+
+	assert.Equal(t, semantic.Removed, byName["Remove"].Kind)
+	assert.Equal(t, "const Version", byName["Version"].Symbol.Signature, "values are left out")
+}
+
+// TestCompareGoSyntaxError verifies that unparsable files are reported
+func TestCompareGoSyntaxError(t *testing.T) {
+	_, err := semantic.Compare("broken.go", "", "package x\nfunc {")
+	assert.Error(t, err)
+
+	_, err = semantic.Compare("notes.txt", "", "hello")
+	assert.ErrorIs(t, err, semantic.ErrUnsupported)
+}
+
+// TestComparePatterns verifies the line-based parsers for Python and
+// TypeScript
+func TestComparePatterns(t *testing.T) {
+	changes, err := semantic.Compare("app.py", "def run():\n    pass\n", "def run(debug=False):\n    pass\n\nclass _Cache:\n    pass\n")
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, semantic.Added, changes[0].Kind)
+	assert.False(t, changes[0].Symbol.Exported)
+	assert.Equal(t, "def run(debug)", changes[1].Symbol.Signature)
+	assert.True(t, changes[1].APIChange())
+
+	changes, err = semantic.Compare("api.ts", "", "export function load(id: string): Item {\n  return get(id)\n}\nconst cache = new Map()\nexport type Item = { id: string }\n")
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "export type Item", changes[0].Symbol.Signature)
+	assert.Equal(t, "const cache", changes[1].Symbol.Signature)
+	assert.False(t, changes[1].Symbol.Exported)
+	assert.Equal(t, "export function load(id: string): Item", changes[2].Symbol.Signature)
+}
+
+// TestPythonSignatures verifies that one-line bodies are left out of Python
+// signatures
+func TestPythonSignatures(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "def run():", want: "def run()"},
+		{line: `def token(): return "secret"`, want: "def token()"},
+		{line: `def get(key: str = "a:b") -> Dict[str, int]: return {"k": 1}`, want: "def get(key: str) -> Dict[str, int]"},
+		{line: `async def fetch(url): return await get(url, token="x")`, want: "async def fetch(url)"},
+		{line: `class Config: key = "secret"`, want: "class Config"},
+		{line: `class Handler(Base, metaclass=Meta): pass`, want: "class Handler(Base, metaclass)"},
+	}
+
+	for _, tt := range tests {
+		changes, err := semantic.Compare("app.py", "", tt.line+"\n")
+		require.NoError(t, err)
+		require.Len(t, changes, 1, tt.line)
+		assert.Equal(t, tt.want, changes[0].Symbol.Signature, tt.line)
+	}
+}
+
+// TestSignatureDefaults verifies that parameter defaults, which may hold
+// secrets, are left out of signatures
+func TestSignatureDefaults(t *testing.T) {
+	changes, err := semantic.Compare("db.py", "", "def connect(password=\"hunter2\", retries = 3, *, opts={\"a\": [1, 2]}):\n    pass\n")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "def connect(password, retries, *, opts)", changes[0].Symbol.Signature)
+
+	changes, err = semantic.Compare("db.ts", "", "export function open(url = \"a,b)\", check: (x: number) => boolean = (x) => x >= 0): void {\n}\n")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "export function open(url, check: (x: number) => boolean): void", changes[0].Symbol.Signature)
+
+	changes, err = semantic.Compare("log.ts", "", "export function log(level: \"a\\\"b\", token = \"x\\\",y\"): void {\n}\n")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, `export function log(level: "a\"b", token): void`, changes[0].Symbol.Signature)
+}
+
+// TestRender verifies the prompt listing and its line limit
+func TestRender(t *testing.T) {
+	files := []semantic.File{{Path: "server.go", Changes: []semantic.Change{
+		{Kind: semantic.Modified, Symbol: semantic.Symbol{Signature: "func helper()"}},
+		{Kind: semantic.Added, Symbol: semantic.Symbol{Signature: "func New() *Server", Exported: true}},
+	}}}
+
+	out := semantic.Render(files, 10)
+	assert.Contains(t, out, "  server.go\n    + func New() *Server [API]\n    ~ func helper()\n")
+
+	out = semantic.Render(files, 1)
+	assert.NotContains(t, out, "helper")
+	assert.Contains(t, out, "[... 1 more changed symbols not shown]")
+}
@@ -3,18 +3,30 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
 	"gic/internal/app"
 	"gic/internal/auth"
+	"gic/internal/client"
+	"gic/internal/commit"
+	"gic/internal/config"
+	"gic/internal/git"
 	"gic/internal/mcp"
+	"gic/internal/secrets"
 
 	"github.com/spf13/cobra"
 	"github.com/yarlson/tap"
 )
 
+// mcpTokenEnv holds the bearer token for the MCP HTTP transport, so it need
+// not appear on the command line.
+const mcpTokenEnv = "GIC_MCP_TOKEN"
+
 // version metadata is injected via ldflags; defaults cover local builds.
 var (
 	version   = "dev"
@@ -24,6 +36,21 @@ var (
 var (
 	showVersion bool
 	autoApprove bool
+	stageMode   string
+	stagePaths  []string
+	interactive bool
+	split       bool
+	candidates  int
+	style       string
+	model       string
+	language    string
+	secretsMode string
+	summarize   bool
+	repoDir     string
+	confirm     bool
+	httpAddr    string
+	httpToken   string
+	allowPaths  []string
 
 	rootCmd = &cobra.Command{
 		Use:           "gic [commit-message]",
@@ -39,7 +66,29 @@ var (
 
 			userInput := strings.Join(args, " ")
 
-			return run(userInput)
+			if err := useRepositoryDir(cmd.Context()); err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if model != "" {
+				cfg.Model = model
+			}
+
+			if err := applyConfig(cfg); err != nil {
+				return err
+			}
+
+			opts, err := appOptions(cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			return run(cmd.Context(), userInput, cfg, opts)
 		},
 	}
 
@@ -54,7 +103,31 @@ var (
 				return nil
 			}
 
-			return runMCP()
+			if err := useRepositoryDir(cmd.Context()); err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			if err := applyConfig(cfg); err != nil {
+				return err
+			}
+
+			// Tool calls layer the .gic.yaml of the repository they act on
+			// over the user's settings themselves
+			userCfg, err := loadUserConfig()
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("confirm") {
+				userCfg.ConfirmCommits = &confirm
+			}
+
+			return runMCP(cmd.Context(), userCfg)
 		},
 	}
 
@@ -70,7 +143,14 @@ var (
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	// Ctrl-C and SIGTERM cancel in-flight git commands and API requests
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := rootCmd.ExecuteContext(ctx)
+
+	stop()
+
+	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 
 		os.Exit(1)
@@ -79,7 +159,22 @@ func main() {
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&showVersion, "version", "v", false, "Show version information")
+	rootCmd.PersistentFlags().StringVarP(&repoDir, "repo-path", "C", "", "Run as if gic was started in this directory")
 	rootCmd.Flags().BoolVarP(&autoApprove, "auto-approve", "y", false, "Skip confirmation prompt and create the commit automatically")
+	rootCmd.Flags().StringVarP(&stageMode, "stage", "s", "", "Staging mode: auto, staged, tracked, all or paths (default auto)")
+	rootCmd.Flags().StringSliceVar(&stagePaths, "path", nil, "Pathspec to stage and commit (repeatable, implies --stage paths)")
+	rootCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Pick individual files and hunks to stage before generating the message")
+	rootCmd.Flags().BoolVar(&split, "split", false, "Let Claude split the staged changes into several logical commits")
+	rootCmd.Flags().IntVarP(&candidates, "candidates", "n", 1, "Number of alternative messages to choose from (1-5)")
+	rootCmd.Flags().StringVar(&style, "style", "", "Commit message style: default or conventional")
+	rootCmd.Flags().StringVar(&model, "model", "", "Claude model used to generate messages")
+	rootCmd.Flags().StringVar(&language, "language", "", "Language to write commit messages in")
+	rootCmd.Flags().StringVar(&secretsMode, "secrets", "", "Handling of secrets found in the diff: redact, block or off (default redact)")
+	rootCmd.Flags().BoolVar(&summarize, "summarize", false, "Summarize files too large for the prompt instead of leaving them out")
+	mcpCmd.Flags().BoolVar(&confirm, "confirm", false, "Ask the user through the MCP client before create_commit commits")
+	mcpCmd.Flags().StringVar(&httpAddr, "http", "", "Serve streamable HTTP and SSE on this address (e.g. localhost:8080) instead of stdio")
+	mcpCmd.Flags().StringVar(&httpToken, "http-token", "", "Bearer token HTTP clients must send (default $"+mcpTokenEnv+")")
+	mcpCmd.Flags().StringSliceVar(&allowPaths, "allow-path", nil, "Directory whose repositories tools may reach through repo_path, besides the client's roots (repeatable)")
 	rootCmd.AddCommand(mcpCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -104,45 +199,280 @@ func printVersion() {
 	tap.Outro("Run `gic` without flags to launch the assistant ✨")
 }
 
-func run(userInput string) error {
+// loadConfig reads the user-level config file and, inside a repository, the
+// repository's .gic.yaml, warning about repository settings it ignores.
+func loadConfig(ctx context.Context) (*config.Config, error) {
+	userPath, err := config.UserPath()
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath := ""
+	if root, err := git.TopLevel(ctx); err == nil {
+		repoPath = filepath.Join(root, config.FileName)
+	}
+
+	cfg, warnings, err := config.Load(userPath, repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, warning := range warnings {
+		_, _ = fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	return cfg, nil
+}
+
+// loadUserConfig reads only the user-level config file.
+func loadUserConfig() (*config.Config, error) {
+	userPath, err := config.UserPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := config.Load(userPath, "")
+
+	return cfg, err
+}
+
+// useRepositoryDir makes git commands run in the -C directory, so that the
+// repository's config file is found there as well.
+func useRepositoryDir(ctx context.Context) error {
+	if repoDir == "" {
+		return nil
+	}
+
+	repo, err := git.OpenWorktree(ctx, git.BackendAuto, repoDir, git.Options{})
+	if err != nil {
+		return err
+	}
+
+	git.Use(repo)
+
+	return nil
+}
+
+// applyConfig reopens the repository with the configured backend and the
+// settings that affect its git commands.
+func applyConfig(cfg *config.Config) error {
+	backend, err := git.ParseBackend(cfg.GitBackend)
+	if err != nil {
+		return err
+	}
+
+	dir := repoDir
+	if dir == "" {
+		dir = "."
+	}
+
+	repo, err := git.Open(backend, dir, git.Options{Exclude: cfg.Exclude, Timeout: cfg.GitTimeout})
+	if err != nil {
+		return err
+	}
+
+	git.Use(repo)
+
+	return nil
+}
+
+// appOptions builds the commit workflow options from command-line flags,
+// falling back to the config file for flags that were not given.
+func appOptions(cmd *cobra.Command, cfg *config.Config) (app.Options, error) {
+	flags := cmd.Flags()
+
+	modeName := stageMode
+	if !flags.Changed("stage") && len(stagePaths) == 0 && !interactive {
+		modeName = cfg.Stage
+	}
+
+	mode, err := git.ParseStageMode(modeName)
+	if err != nil {
+		return app.Options{}, err
+	}
+
+	if interactive && stageMode != "" {
+		return app.Options{}, fmt.Errorf("--interactive cannot be combined with --stage")
+	}
+
+	if len(stagePaths) > 0 && !interactive {
+		if stageMode != "" && mode != git.StagePaths {
+			return app.Options{}, fmt.Errorf("--path cannot be combined with --stage %s", mode)
+		}
+
+		mode = git.StagePaths
+	}
+
+	styleName := style
+	if !flags.Changed("style") {
+		styleName = cfg.Style
+	}
+
+	messageStyle, err := commit.ParseStyle(styleName)
+	if err != nil {
+		return app.Options{}, err
+	}
+
+	if candidates < 1 || candidates > commit.MaxCandidates {
+		return app.Options{}, fmt.Errorf("--candidates must be between 1 and %d", commit.MaxCandidates)
+	}
+
+	approve := autoApprove
+	if !flags.Changed("auto-approve") && cfg.AutoApprove != nil {
+		approve = *cfg.AutoApprove
+	}
+
+	secretsName := secretsMode
+	if !flags.Changed("secrets") {
+		secretsName = cfg.Secrets
+	}
+
+	secretsHandling, err := secrets.ParseMode(secretsName)
+	if err != nil {
+		return app.Options{}, err
+	}
+
+	summarizeLarge := summarize
+	if !flags.Changed("summarize") && cfg.Summarize != nil {
+		summarizeLarge = *cfg.Summarize
+	}
+
+	messageLanguage := language
+	if messageLanguage == "" {
+		messageLanguage = cfg.Language
+	}
+
+	return app.Options{
+		AutoApprove: approve,
+		Stage:       mode,
+		Paths:       stagePaths,
+		Interactive: interactive,
+		Split:       split,
+		Candidates:  candidates,
+		Message: commit.Options{
+			Style:           messageStyle,
+			Language:        messageLanguage,
+			Instructions:    cfg.Prompt,
+			ContextWindow:   cfg.ProviderConfig().ContextWindow(),
+			MaxPromptTokens: cfg.MaxPromptTokens,
+			MaxPromptChars:  cfg.MaxPromptChars,
+			Summarize:       summarizeLarge,
+		},
+		Secrets: secretsHandling,
+	}, nil
+}
+
+func run(ctx context.Context, userInput string, cfg *config.Config, opts app.Options) error {
+	tokenPath, credentialsPath, err := authPaths()
+	if err != nil {
+		return err
+	}
+
+	providerConfig := cfg.ProviderConfig()
+
+	accessToken := ""
+
+	if providerConfig.IsAnthropic() {
+		cred, err := resolveCredential(credentialsPath, providerConfig)
+		if err != nil {
+			return err
+		}
+
+		if cred.Method == auth.MethodAPIKey {
+			providerConfig.APIKey = cred.APIKey
+		} else {
+			token, err := loadOAuthToken(ctx, tokenPath)
+			if err != nil {
+				return err
+			}
+
+			accessToken = token.AccessToken
+		}
+	}
+
+	provider, err := client.NewProvider(providerConfig, accessToken)
+	if err != nil {
+		return err
+	}
+
+	// Run commit workflow
+	return app.Run(ctx, provider, userInput, opts)
+}
+
+// authPaths returns the locations of the stored OAuth token and credentials.
+func authPaths() (tokenPath, credentialsPath string, err error) {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
-		return fmt.Errorf("failed to get config dir: %w", err)
+		return "", "", fmt.Errorf("failed to get config dir: %w", err)
+	}
+
+	dir := filepath.Join(configDir, "gic")
+
+	return filepath.Join(dir, "tokens.json"), filepath.Join(dir, auth.CredentialsFile), nil
+}
+
+// resolveCredential picks the Anthropic credential for a provider; the
+// anthropic-api-key provider never falls back to OAuth.
+func resolveCredential(credentialsPath string, providerConfig client.ProviderConfig) (*auth.Credential, error) {
+	method := auth.MethodAuto
+	if providerConfig.Name == client.ProviderAnthropicAPIKey {
+		method = auth.MethodAPIKey
 	}
 
-	tokenPath := filepath.Join(configDir, "gic", "tokens.json")
+	return auth.Resolve(credentialsPath, providerConfig.APIKeyEnv, method)
+}
 
+// loadOAuthToken returns a valid claude.ai token, running the OAuth flow
+// when none is stored.
+func loadOAuthToken(ctx context.Context, tokenPath string) (*auth.Token, error) {
 	// Try to load existing token
 	token, err := auth.Load(tokenPath)
 	if err != nil || token == nil {
 		// No token found, run OAuth flow
 		tap.Intro("🔐 Authentication Required")
 
-		token, err = performOAuthFlow(tokenPath)
+		token, err = performOAuthFlow(ctx, tokenPath)
 		if err != nil {
-			return fmt.Errorf("oauth flow failed: %w", err)
+			return nil, fmt.Errorf("oauth flow failed: %w", err)
 		}
 	}
 
 	// Ensure token is valid (refresh if needed)
 	token, err = auth.EnsureValid(token, tokenPath, auth.ClientID, auth.TokenURL)
 	if err != nil {
-		return fmt.Errorf("failed to get valid token: %w", err)
+		return nil, fmt.Errorf("failed to get valid token: %w", err)
 	}
 
-	// Run commit workflow
-	return app.Run(token.AccessToken, userInput, autoApprove)
+	return token, nil
 }
 
-func performOAuthFlow(tokenPath string) (*auth.Token, error) {
-	ctx := context.Background()
-
+func performOAuthFlow(ctx context.Context, tokenPath string) (*auth.Token, error) {
 	// Use claude.ai OAuth (Pro/Max)
-	authURL, verifier, err := auth.BuildAuthURL(false)
+	token, sp, err := authorize(ctx, false)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := auth.Save(token, tokenPath); err != nil {
+		sp.Stop("Failed to save token", 2)
+		return nil, fmt.Errorf("failed to save token: %w", err)
+	}
+
+	sp.Stop("Authorization successful!", 0)
+	tap.Outro("You're all set! 🎉")
+
+	return token, nil
+}
+
+// authorize runs the OAuth code flow against claude.ai or, with useConsole,
+// the Anthropic Console. The returned spinner is still running so callers
+// can finish their own follow-up step under it.
+func authorize(ctx context.Context, useConsole bool) (*auth.Token, *tap.Spinner, error) {
+	authURL, verifier, err := auth.BuildAuthURL(useConsole)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	tap.Message("Please visit this URL to authorize:")
 	tap.Message(authURL)
 
@@ -151,7 +481,7 @@ func performOAuthFlow(tokenPath string) (*auth.Token, error) {
 	})
 
 	if authCode == "" {
-		return nil, fmt.Errorf("authorization cancelled")
+		return nil, nil, fmt.Errorf("authorization cancelled")
 	}
 
 	sp := tap.NewSpinner(tap.SpinnerOptions{Indicator: "dots"})
@@ -160,42 +490,56 @@ func performOAuthFlow(tokenPath string) (*auth.Token, error) {
 	token, err := auth.ExchangeCode(authCode, verifier)
 	if err != nil {
 		sp.Stop("Failed to exchange code", 2)
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
+		return nil, nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
 
-	if err := auth.Save(token, tokenPath); err != nil {
-		sp.Stop("Failed to save token", 2)
-		return nil, fmt.Errorf("failed to save token: %w", err)
-	}
-
-	sp.Stop("Authorization successful!", 0)
-	tap.Outro("You're all set! 🎉")
-
-	return token, nil
+	return token, sp, nil
 }
 
-func runMCP() error {
-	configDir, err := os.UserConfigDir()
+func runMCP(ctx context.Context, cfg *config.Config) error {
+	tokenPath, credentialsPath, err := authPaths()
 	if err != nil {
-		return fmt.Errorf("failed to get config dir: %w", err)
+		return err
 	}
 
-	tokenPath := filepath.Join(configDir, "gic", "tokens.json")
+	accessToken := ""
 
-	// Try to load existing token
-	token, err := auth.Load(tokenPath)
-	if err != nil || token == nil {
-		return fmt.Errorf("authentication required: please run 'gic' first to authenticate")
-	}
+	if providerConfig := cfg.ProviderConfig(); providerConfig.IsAnthropic() {
+		cred, err := resolveCredential(credentialsPath, providerConfig)
+		if err != nil {
+			return err
+		}
 
-	// Ensure token is valid (refresh if needed)
-	token, err = auth.EnsureValid(token, tokenPath, auth.ClientID, auth.TokenURL)
-	if err != nil {
-		return fmt.Errorf("failed to get valid token: %w", err)
+		if cred.Method == auth.MethodOAuth {
+			// Without a token, tool calls need a client that supports sampling
+			token, err := auth.Load(tokenPath)
+			if err != nil || token == nil {
+				log.Printf("No stored token: generating messages requires an MCP client with sampling support, 'gic auth login' or %s", auth.DefaultAPIKeyEnv)
+			} else {
+				// Ensure token is valid (refresh if needed)
+				token, err = auth.EnsureValid(token, tokenPath, auth.ClientID, auth.TokenURL)
+				if err != nil {
+					return fmt.Errorf("failed to get valid token: %w", err)
+				}
+
+				accessToken = token.AccessToken
+			}
+		}
 	}
 
 	// Create and run MCP server
-	server := mcp.NewServer(token.AccessToken, tokenPath)
+	server := mcp.NewServer(accessToken, tokenPath)
+	server.SetConfig(cfg)
+	server.AllowPaths(allowPaths...)
+
+	if httpAddr != "" {
+		token := httpToken
+		if token == "" {
+			token = os.Getenv(mcpTokenEnv)
+		}
+
+		return server.RunHTTP(ctx, httpAddr, token)
+	}
 
-	return server.Run(context.Background())
+	return server.Run(ctx)
 }
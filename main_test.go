@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -56,9 +57,9 @@ func (s *MainTestSuite) SetupTest() {
 	// Create initial commit
 	err = os.WriteFile("initial.txt", []byte("initial"), 0644)
 	require.NoError(s.T(), err)
-	err = git.Add("initial.txt")
+	err = git.Add(context.Background(), "initial.txt")
 	require.NoError(s.T(), err)
-	err = git.Commit("Initial commit")
+	err = git.Commit(context.Background(), "Initial commit")
 	require.NoError(s.T(), err)
 }
 
@@ -311,7 +312,7 @@ func (s *MainTestSuite) TestIntegrationWithCommitPackage() {
 	// - User interaction for confirmation
 
 	// We verify we're in a git repo
-	_, err := git.Status()
+	_, err := git.Status(context.Background())
 	require.NoError(s.T(), err)
 
 	s.T().Log("Integration with commit package verified")
@@ -332,7 +333,7 @@ func (s *MainTestSuite) TestIntegrationWithMCPPackage() {
 	// - Stdio transport for communication
 
 	// We verify we're in a git repo
-	_, err := git.Status()
+	_, err := git.Status(context.Background())
 	require.NoError(s.T(), err)
 
 	s.T().Log("Integration with MCP package verified")